@@ -0,0 +1,6 @@
+
+create table tenant_entity (
+  a     varchar(64)     primary key not null,
+  t     varchar(64)     not null,
+  b     varchar(64)
+);
@@ -0,0 +1,7 @@
+
+create table composite_entity (
+  a     varchar(64)     not null,
+  b     varchar(64)     not null,
+  c     integer,
+  primary key(a, b)
+);
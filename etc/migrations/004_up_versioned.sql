@@ -0,0 +1,5 @@
+create table versioned_entity (
+  a     varchar(64)     primary key not null,
+  b     varchar(64),
+  v     integer         not null
+);
@@ -0,0 +1,5 @@
+create table soft_entity (
+  a     varchar(64)     primary key not null,
+  b     varchar(64),
+  d     timestamp with time zone
+);
@@ -0,0 +1,6 @@
+create table stamped_entity (
+  a     varchar(64)     primary key not null,
+  b     varchar(64),
+  c     timestamp with time zone not null,
+  u     timestamp with time zone not null
+);
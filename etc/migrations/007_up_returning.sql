@@ -0,0 +1,7 @@
+create table returning_entity (
+  a     varchar(64)     primary key not null,
+  b     varchar(64),
+  c     timestamp with time zone not null default now(),
+  n     serial,
+  u     varchar(64)     generated always as (upper(b)) stored
+);
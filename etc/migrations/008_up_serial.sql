@@ -0,0 +1,4 @@
+create table serial_entity (
+  id    bigserial       primary key not null,
+  b     varchar(64)
+);
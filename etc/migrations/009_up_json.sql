@@ -0,0 +1,6 @@
+
+create table json_entity (
+  a     varchar(64)     primary key not null,
+  b     jsonb,
+  c     json
+);
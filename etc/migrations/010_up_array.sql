@@ -0,0 +1,7 @@
+
+create table array_entity (
+  a     varchar(64)     primary key not null,
+  b     text[],
+  c     bigint[],
+  d     integer
+);
@@ -0,0 +1,6 @@
+
+create table encrypted_entity (
+  a     varchar(64)     primary key not null,
+  b     bytea,
+  c     bytea
+);
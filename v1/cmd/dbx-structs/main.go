@@ -0,0 +1,105 @@
+// dbx-structs generates Go entity structs from the tables in a database.
+//
+//	dbx-structs -db postgres://localhost/example -package entities -o entities.go
+//
+// Every table in the current schema is generated unless tables are named
+// with -tables. Nullable columns are pointers unless -nullable=omitempty is
+// provided, in which case they are values tagged with omitempty. SQLite
+// databases are supported with DSNs like sqlite:///path/to/example.db.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bww/go-dbx/v1"
+	"github.com/bww/go-dbx/v1/schema"
+)
+
+func main() {
+	os.Exit(app(os.Args[1:]))
+}
+
+func app(args []string) int {
+	cmdline := flag.NewFlagSet("dbx-structs", flag.ExitOnError)
+	var (
+		fDSN      = cmdline.String("db", os.Getenv("DATABASE_URL"), "The database to read, as a URL; defaults to $DATABASE_URL")
+		fPackage  = cmdline.String("package", "entities", "The package the generated source is declared in")
+		fTables   = cmdline.String("tables", "", "A comma-separated list of tables to generate; defaults to every table")
+		fNullable = cmdline.String("nullable", "pointer", "How nullable columns are represented: 'pointer' or 'omitempty'")
+		fTabler   = cmdline.Bool("tabler", true, "Generate a Table method for each struct")
+		fOutput   = cmdline.String("o", "", "The file to write; defaults to standard output")
+	)
+	cmdline.Parse(args)
+
+	if *fDSN == "" {
+		fmt.Fprintln(os.Stderr, "dbx-structs: No database provided; use -db")
+		return 1
+	}
+
+	conf := schema.StructConfig{
+		Package: *fPackage,
+		Tabler:  *fTabler,
+	}
+	switch *fNullable {
+	case "pointer":
+		conf.Nullable = schema.NullablePointer
+	case "omitempty":
+		conf.Nullable = schema.NullableOmitEmpty
+	default:
+		fmt.Fprintf(os.Stderr, "dbx-structs: Invalid nullable representation: %s\n", *fNullable)
+		return 1
+	}
+
+	db, err := dbx.New(*fDSN)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dbx-structs: Could not connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	var names []string
+	if *fTables != "" {
+		for _, e := range strings.Split(*fTables, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				names = append(names, e)
+			}
+		}
+	} else {
+		names, err = schema.ListTables(db)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "dbx-structs: Could not list tables: %v\n", err)
+			return 1
+		}
+	}
+
+	tables := make([]*schema.Table, 0, len(names))
+	for _, e := range names {
+		t, err := schema.ReadTable(db, e)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "dbx-structs: Could not read table %s: %v\n", e, err)
+			return 1
+		}
+		tables = append(tables, t)
+	}
+
+	src, err := schema.GenerateStructs(tables, conf)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dbx-structs: Could not generate source: %v\n", err)
+		return 1
+	}
+
+	if *fOutput == "" {
+		_, err = os.Stdout.Write(src)
+	} else {
+		err = os.WriteFile(*fOutput, src, 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dbx-structs: Could not write source: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
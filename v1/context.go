@@ -18,6 +18,17 @@ type Context interface {
 	QueryRowx(query string, args ...interface{}) *sqlx.Row
 }
 
+// A context which is able to prepare statements. This is required for some
+// operations, like COPY, which are not supported by every context.
+type Preparer interface {
+	Prepare(query string) (*sql.Stmt, error)
+}
+
+// A context which can report the name of the driver it uses.
+type DriverNamer interface {
+	DriverName() string
+}
+
 func (d *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
 	if d.debug {
 		d.log.Printf("dbx/exec: (%T) [%s] %v\n", d, text.CollapseSpaces(query), args)
@@ -122,6 +133,24 @@ func (c *wrappedTx) QueryRowx(query string, args ...interface{}) *sqlx.Row {
 	return c.Tx.QueryRowx(query, args...)
 }
 
+func (c *wrappedTx) Prepare(query string) (*sql.Stmt, error) {
+	p, ok := c.Tx.(Preparer)
+	if !ok {
+		return nil, ErrDriverNotSupported
+	}
+	if c.debug {
+		c.log.Printf("dbx/prepare: (%T) [%s]\n", c.Tx, text.CollapseSpaces(query))
+	}
+	return p.Prepare(query)
+}
+
+func (c *wrappedTx) DriverName() string {
+	if n, ok := c.Tx.(DriverNamer); ok {
+		return n.DriverName()
+	}
+	return ""
+}
+
 func (c *wrappedTx) Commit() error {
 	if c.debug {
 		c.log.Printf("dbx/commit: (%T)\n", c.Tx)
@@ -0,0 +1,14 @@
+package entity
+
+import (
+	"reflect"
+)
+
+// IsArray determines if the field of a type identified by the provided
+// traversal is stored as a Postgres array. Such fields are slices of scalar
+// values tagged with the array option, for example:
+//
+//	Tags []string `db:"tags,array"`
+func (m *FieldMapper) IsArray(typ reflect.Type, index []int) bool {
+	return m.fieldHasOption(typ, index, optionArray)
+}
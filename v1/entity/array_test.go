@@ -0,0 +1,42 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type arrayEntity struct {
+	A string   `db:"a,pk"`
+	B []string `db:"b,array"`
+	C []int64  `db:"c,array"`
+	D []byte   `db:"d"`
+}
+
+func TestArrays(t *testing.T) {
+	m := NewFieldMapper()
+	typ := reflect.TypeOf(arrayEntity{})
+
+	e := &arrayEntity{A: "A", B: []string{"x", "y z"}, D: []byte("D")}
+	_, cols := m.Columns(e)
+	vals := make([]interface{}, len(cols.Vals))
+	for i, v := range cols.Vals {
+		if c, ok := v.(driver.Valuer); ok {
+			x, err := c.Value()
+			assert.Nil(t, err, fmt.Sprint(err))
+			vals[i] = x
+		} else {
+			vals[i] = v
+		}
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d"}, cols.Cols)
+	assert.Equal(t, []interface{}{"A", `{"x","y z"}`, nil, []byte("D")}, vals)
+
+	for i, n := range []string{"a", "b", "c", "d"} {
+		f := m.TypeMap(typ).GetByPath(n)
+		assert.Equal(t, i == 1 || i == 2, m.IsArray(typ, f.Index), n)
+	}
+}
@@ -0,0 +1,128 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+
+	"github.com/bww/go-dbx/v1"
+)
+
+// MapperOption configures a FieldMapper.
+type MapperOption func(*FieldMapper)
+
+// Converter converts values of a Go type to and from their database
+// representation, allowing fields of types which implement neither
+// driver.Valuer nor sql.Scanner to be stored and fetched.
+type Converter struct {
+	// Value converts a field value to a value that can be provided to the
+	// database driver.
+	Value func(interface{}) (driver.Value, error)
+	// Scan converts a value read from the database, which is never nil, to
+	// a value which is assignable to a field.
+	Scan func(interface{}) (interface{}, error)
+}
+
+// WithConverter registers functions which convert values of type T to and
+// from their database representation. Fields of type T and *T are converted;
+// a nil pointer is stored as NULL and NULL is fetched as a nil pointer or as
+// the zero value of T. For example:
+//
+//	fm := entity.NewFieldMapper(
+//	  entity.WithConverter(
+//	    func(v netip.Addr) (driver.Value, error) { return v.String(), nil },
+//	    func(src interface{}) (netip.Addr, error) { return netip.ParseAddr(fmt.Sprintf("%s", src)) },
+//	  ),
+//	)
+func WithConverter[T any](value func(T) (driver.Value, error), scan func(interface{}) (T, error)) MapperOption {
+	return func(m *FieldMapper) {
+		if m.converters == nil {
+			m.converters = make(map[reflect.Type]Converter)
+		}
+		m.converters[reflect.TypeOf((*T)(nil)).Elem()] = Converter{
+			Value: func(v interface{}) (driver.Value, error) {
+				return value(v.(T))
+			},
+			Scan: func(src interface{}) (interface{}, error) {
+				return scan(src)
+			},
+		}
+	}
+}
+
+// converterForType produces the converter for a type, if one is registered.
+// The second result is true if the converter applies to the element of a
+// pointer type.
+func (m *FieldMapper) converterForType(typ reflect.Type) (Converter, bool, bool) {
+	if len(m.converters) == 0 {
+		return Converter{}, false, false
+	}
+	if c, ok := m.converters[typ]; ok {
+		return c, false, true
+	}
+	if typ.Kind() == reflect.Ptr {
+		if c, ok := m.converters[typ.Elem()]; ok {
+			return c, true, true
+		}
+	}
+	return Converter{}, false, false
+}
+
+// Converts determines if values of the provided type are converted by a
+// registered converter.
+func (m *FieldMapper) Converts(typ reflect.Type) bool {
+	_, _, ok := m.converterForType(typ)
+	return ok
+}
+
+// ConvertFrom converts a value read from the database and assigns it to a
+// field whose type is converted by a registered converter.
+func (m *FieldMapper) ConvertFrom(dst reflect.Value, src interface{}) error {
+	c, ptr, ok := m.converterForType(dst.Type())
+	if !ok {
+		return fmt.Errorf("%w: no converter for %v", dbx.ErrInvalidField, dst.Type())
+	}
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	v, err := c.Scan(src)
+	if err != nil {
+		return err
+	}
+	r := reflect.ValueOf(v)
+	if ptr {
+		p := reflect.New(dst.Type().Elem())
+		p.Elem().Set(r)
+		r = p
+	}
+	dst.Set(r)
+	return nil
+}
+
+// convertTo produces the database value of a field, applying a registered
+// converter if there is one. The conversion is deferred until the value is
+// provided to the driver.
+func (m *FieldMapper) convertTo(v reflect.Value, x interface{}) interface{} {
+	c, ptr, ok := m.converterForType(v.Type())
+	if !ok {
+		return x
+	}
+	if ptr {
+		if v.IsNil() {
+			return nil
+		}
+		x = v.Elem().Interface()
+	}
+	return convertedValue{fn: c.Value, v: x}
+}
+
+// convertedValue converts a value when it is provided to the driver.
+type convertedValue struct {
+	fn func(interface{}) (driver.Value, error)
+	v  interface{}
+}
+
+func (c convertedValue) Value() (driver.Value, error) {
+	return c.fn(c.v)
+}
@@ -0,0 +1,68 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type convertedEntity struct {
+	Tracked
+	A string      `db:"a,pk"`
+	B netip.Addr  `db:"b"`
+	C *netip.Addr `db:"c"`
+}
+
+func newConvertingMapper() *FieldMapper {
+	return NewFieldMapper(
+		WithConverter(
+			func(v netip.Addr) (driver.Value, error) { return v.String(), nil },
+			func(src interface{}) (netip.Addr, error) { return netip.ParseAddr(fmt.Sprintf("%s", src)) },
+		),
+	)
+}
+
+func TestConverters(t *testing.T) {
+	m := newConvertingMapper()
+	addr := netip.MustParseAddr("10.0.0.1")
+
+	e := &convertedEntity{A: "A", B: addr}
+	_, cols := m.Columns(e)
+	if assert.Equal(t, []string{"a", "b", "c"}, cols.Cols) {
+		assert.Equal(t, "A", cols.Vals[0])
+		if v, ok := cols.Vals[1].(driver.Valuer); assert.True(t, ok) {
+			x, err := v.Value()
+			assert.Nil(t, err, fmt.Sprint(err))
+			assert.Equal(t, "10.0.0.1", x)
+		}
+		assert.Nil(t, cols.Vals[2])
+	}
+
+	assert.True(t, m.Converts(reflect.TypeOf(addr)))
+	assert.True(t, m.Converts(reflect.TypeOf(&addr)))
+	assert.False(t, m.Converts(reflect.TypeOf("")))
+	assert.False(t, NewFieldMapper().Converts(reflect.TypeOf(addr)))
+
+	var d convertedEntity
+	v := reflect.ValueOf(&d).Elem()
+	assert.Nil(t, m.ConvertFrom(v.FieldByName("B"), "::1"))
+	assert.Nil(t, m.ConvertFrom(v.FieldByName("C"), []byte("10.0.0.2")))
+	assert.Equal(t, netip.MustParseAddr("::1"), d.B)
+	if assert.NotNil(t, d.C) {
+		assert.Equal(t, netip.MustParseAddr("10.0.0.2"), *d.C)
+	}
+	assert.Nil(t, m.ConvertFrom(v.FieldByName("C"), nil))
+	assert.Nil(t, d.C)
+	assert.NotNil(t, m.ConvertFrom(v.FieldByName("B"), "not an address"))
+
+	m.Snapshot(e)
+	dirty, _ := m.Dirty(e)
+	assert.Equal(t, []string{}, dirty)
+	e.B = netip.MustParseAddr("10.0.0.3")
+	dirty, _ = m.Dirty(e)
+	assert.Equal(t, []string{"b"}, dirty)
+}
@@ -0,0 +1,265 @@
+package entity
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+
+	"github.com/bww/go-dbx/v1"
+)
+
+const encryptDeterministic = "deterministic"
+
+// Cipher encrypts and decrypts column values. Fields tagged with the encrypt
+// option are encrypted when they are written and decrypted when they are
+// read. For example:
+//
+//	SSN   string `db:"ssn,encrypt"`
+//	Email string `db:"email,encrypt=deterministic"`
+//
+// Encrypted fields must be strings or byte slices, or pointers to them, and
+// are stored in bytea columns.
+//
+// Deterministic encryption always produces the same ciphertext for the same
+// plaintext and key, which allows encrypted columns to be compared for
+// equality; see FieldMapper.EncryptedLookup. It reveals which rows share a
+// value and should only be used where that is acceptable.
+type Cipher interface {
+	Encrypt(plaintext []byte, deterministic bool) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// KeyProvider provides the keys used by a cipher. Keys are identified so
+// that they can be rotated: new values are encrypted with the current key
+// while values encrypted with earlier keys can still be decrypted.
+type KeyProvider interface {
+	// CurrentKey produces the key used to encrypt new values and its id.
+	CurrentKey() (string, []byte, error)
+	// Key produces the key with the provided id.
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeys is a KeyProvider for a fixed set of keys.
+type StaticKeys struct {
+	Current string            // the id of the current key
+	Keys    map[string][]byte // AES keys by id; 16, 24 or 32 bytes long
+}
+
+func (k StaticKeys) CurrentKey() (string, []byte, error) {
+	key, err := k.Key(k.Current)
+	if err != nil {
+		return "", nil, err
+	}
+	return k.Current, key, nil
+}
+
+func (k StaticKeys) Key(id string) ([]byte, error) {
+	key, ok := k.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", dbx.ErrKeyNotFound, id)
+	}
+	return key, nil
+}
+
+// WithCipher sets the cipher used to encrypt and decrypt fields tagged with
+// the encrypt option.
+func WithCipher(c Cipher) MapperOption {
+	return func(m *FieldMapper) {
+		m.cipher = c
+	}
+}
+
+type aesCipher struct {
+	keys KeyProvider
+}
+
+// NewAESCipher creates a cipher which uses AES-GCM with keys provided by the
+// provided key provider. The id of the key used to encrypt a value is stored
+// with its ciphertext, which is laid out as:
+//
+//	len(id) | id | nonce | sealed
+//
+// In deterministic mode the nonce is derived from the key and plaintext
+// rather than generated randomly.
+func NewAESCipher(keys KeyProvider) Cipher {
+	return aesCipher{keys: keys}
+}
+
+func (c aesCipher) Encrypt(plaintext []byte, deterministic bool) ([]byte, error) {
+	id, key, err := c.keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(id) > 255 {
+		return nil, fmt.Errorf("%w: key id is too long: %s", dbx.ErrKeyNotFound, id)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if deterministic {
+		copy(nonce, syntheticNonce(key, plaintext))
+	} else if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	hdr := append([]byte{byte(len(id))}, id...)
+	out := make([]byte, 0, len(hdr)+len(nonce)+len(plaintext)+aead.Overhead())
+	out = append(out, hdr...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plaintext, hdr), nil
+}
+
+func (c aesCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < 1 || len(ciphertext) < 1+int(ciphertext[0]) {
+		return nil, dbx.ErrInvalidCiphertext
+	}
+	n := 1 + int(ciphertext[0])
+	hdr, id := ciphertext[:n], string(ciphertext[1:n])
+
+	key, err := c.keys.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := ciphertext[n:]
+	if len(rest) < aead.NonceSize()+aead.Overhead() {
+		return nil, dbx.ErrInvalidCiphertext
+	}
+	nonce, sealed := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, hdr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", dbx.ErrInvalidCiphertext, err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(b)
+}
+
+// syntheticNonce derives a nonce from a key and plaintext. The plaintext is
+// authenticated with a subkey so the key itself is not used for both.
+func syntheticNonce(key, plaintext []byte) []byte {
+	sub := hmac.New(sha256.New, key)
+	sub.Write([]byte("dbx:nonce"))
+	mac := hmac.New(sha256.New, sub.Sum(nil))
+	mac.Write(plaintext)
+	return mac.Sum(nil)
+}
+
+// IsEncrypted determines if the field of a type identified by the provided
+// traversal is encrypted.
+func (m *FieldMapper) IsEncrypted(typ reflect.Type, index []int) bool {
+	return m.fieldHasOption(typ, index, optionEncrypt)
+}
+
+// EncryptedLookup produces a query argument which matches a column encrypted
+// in deterministic mode. Only values encrypted with the current key will
+// match; values encrypted with a key that has since been rotated will not.
+func (m *FieldMapper) EncryptedLookup(v interface{}) driver.Valuer {
+	return encryptedValue{cipher: m.cipher, v: reflect.ValueOf(v), deterministic: true}
+}
+
+// NewDecrypter creates a scanner which decrypts a value read from the
+// database into the provided field, which must be settable.
+func (m *FieldMapper) NewDecrypter(dst reflect.Value) sql.Scanner {
+	return decrypter{cipher: m.cipher, dst: dst}
+}
+
+// encryptedValue encrypts a value when it is provided to the driver.
+type encryptedValue struct {
+	cipher        Cipher
+	v             reflect.Value
+	deterministic bool
+}
+
+func (e encryptedValue) Value() (driver.Value, error) {
+	if e.cipher == nil {
+		return nil, dbx.ErrNoCipher
+	}
+	v := e.v
+	if !v.IsValid() || isNil(v) {
+		return nil, nil
+	}
+	v = reflect.Indirect(v)
+	var plaintext []byte
+	switch {
+	case v.Kind() == reflect.String:
+		plaintext = []byte(v.String())
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		plaintext = v.Bytes()
+	default:
+		return nil, fmt.Errorf("%w: cannot encrypt %v", dbx.ErrInvalidField, v.Type())
+	}
+	return e.cipher.Encrypt(plaintext, e.deterministic)
+}
+
+// snapshot produces the plaintext of an encrypted value so that tracking
+// is not affected by ciphertext which differs each time it is produced.
+func (e encryptedValue) snapshot() interface{} {
+	if !e.v.IsValid() || isNil(e.v) {
+		return nil
+	}
+	return reflect.Indirect(e.v).Interface()
+}
+
+// decrypter decrypts a value read from the database into a field.
+type decrypter struct {
+	cipher Cipher
+	dst    reflect.Value
+}
+
+func (d decrypter) Scan(src interface{}) error {
+	if src == nil {
+		d.dst.Set(reflect.Zero(d.dst.Type()))
+		return nil
+	}
+	if d.cipher == nil {
+		return dbx.ErrNoCipher
+	}
+	ciphertext, ok := src.([]byte)
+	if !ok {
+		return fmt.Errorf("%w: cannot decrypt %T", dbx.ErrInvalidCiphertext, src)
+	}
+	plaintext, err := d.cipher.Decrypt(ciphertext)
+	if err != nil {
+		return err
+	}
+
+	t := d.dst.Type()
+	ptr := t.Kind() == reflect.Ptr
+	if ptr {
+		t = t.Elem()
+	}
+	v := reflect.New(t).Elem()
+	switch {
+	case t.Kind() == reflect.String:
+		v.SetString(string(plaintext))
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		v.SetBytes(plaintext)
+	default:
+		return fmt.Errorf("%w: cannot decrypt into %v", dbx.ErrInvalidField, d.dst.Type())
+	}
+	if ptr {
+		v = v.Addr()
+	}
+	d.dst.Set(v)
+	return nil
+}
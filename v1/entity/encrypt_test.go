@@ -0,0 +1,114 @@
+package entity
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/bww/go-dbx/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+type encryptedEntity struct {
+	Tracked
+	A string  `db:"a,pk"`
+	B string  `db:"b,encrypt"`
+	C *string `db:"c,encrypt=deterministic"`
+	D []byte  `db:"d,encrypt,omitempty"`
+}
+
+func testKeys(current string) StaticKeys {
+	return StaticKeys{
+		Current: current,
+		Keys: map[string][]byte{
+			"k1": bytes.Repeat([]byte{1}, 32),
+			"k2": bytes.Repeat([]byte{2}, 16),
+		},
+	}
+}
+
+func TestAESCipher(t *testing.T) {
+	c1 := NewAESCipher(testKeys("k1"))
+	c2 := NewAESCipher(testKeys("k2"))
+	plaintext := []byte("Hello, there")
+
+	x1, err := c1.Encrypt(plaintext, false)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, "k1", string(x1[1:1+x1[0]]))
+		x2, err := c1.Encrypt(plaintext, false)
+		assert.Nil(t, err, fmt.Sprint(err))
+		assert.NotEqual(t, x1, x2)
+	}
+
+	d1, err := c1.Encrypt(plaintext, true)
+	assert.Nil(t, err, fmt.Sprint(err))
+	d2, err := c1.Encrypt(plaintext, true)
+	assert.Nil(t, err, fmt.Sprint(err))
+	assert.Equal(t, d1, d2)
+
+	// values encrypted with a previous key can be decrypted after rotation
+	for _, e := range [][]byte{x1, d1} {
+		r, err := c2.Decrypt(e)
+		if assert.Nil(t, err, fmt.Sprint(err)) {
+			assert.Equal(t, plaintext, r)
+		}
+	}
+
+	x1[len(x1)-1] ^= 0xff
+	_, err = c1.Decrypt(x1)
+	assert.ErrorIs(t, err, dbx.ErrInvalidCiphertext)
+	_, err = c1.Decrypt([]byte{9, 'k'})
+	assert.ErrorIs(t, err, dbx.ErrInvalidCiphertext)
+	_, err = NewAESCipher(testKeys("k3")).Encrypt(plaintext, false)
+	assert.ErrorIs(t, err, dbx.ErrKeyNotFound)
+}
+
+func TestEncryptedColumns(t *testing.T) {
+	ciph := NewAESCipher(testKeys("k1"))
+	m := NewFieldMapper(WithCipher(ciph))
+	typ := reflect.TypeOf(encryptedEntity{})
+	email := "bob@example.com"
+
+	e := &encryptedEntity{A: "A", B: "Secret", C: &email}
+	_, cols := m.Columns(e)
+	if assert.Equal(t, []string{"a", "b", "c", "d"}, cols.Cols) {
+		assert.Equal(t, "A", cols.Vals[0])
+		assert.Nil(t, cols.Vals[3])
+
+		var d encryptedEntity
+		v := reflect.ValueOf(&d).Elem()
+		for i, n := range []string{"B", "C"} {
+			x, err := cols.Vals[i+1].(driver.Valuer).Value()
+			if assert.Nil(t, err, fmt.Sprint(err)) {
+				err = m.NewDecrypter(v.FieldByName(n)).Scan(x)
+				assert.Nil(t, err, fmt.Sprint(err))
+			}
+		}
+		assert.Equal(t, "Secret", d.B)
+		assert.Equal(t, &email, d.C)
+
+		x, _ := cols.Vals[2].(driver.Valuer).Value()
+		y, err := m.EncryptedLookup(email).Value()
+		if assert.Nil(t, err, fmt.Sprint(err)) {
+			assert.Equal(t, x, y)
+		}
+	}
+
+	for i, n := range []string{"a", "b", "c", "d"} {
+		f := m.TypeMap(typ).GetByPath(n)
+		assert.Equal(t, i > 0, m.IsEncrypted(typ, f.Index), n)
+	}
+
+	m.Snapshot(e)
+	dirty, _ := m.Dirty(e)
+	assert.Equal(t, []string{}, dirty)
+	e.B = "Changed"
+	dirty, _ = m.Dirty(e)
+	assert.Equal(t, []string{"b"}, dirty)
+
+	_, cols = NewFieldMapper().Columns(e)
+	_, err := cols.Vals[1].(driver.Valuer).Value()
+	assert.ErrorIs(t, err, dbx.ErrNoCipher)
+}
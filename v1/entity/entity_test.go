@@ -1,5 +1,9 @@
 package entity
 
+import (
+	"time"
+)
+
 type embedEntity struct {
 	B string `db:"y"`
 }
@@ -17,6 +21,51 @@ type multiPKEntity struct {
 	C string `db:"x,pk"`
 }
 
+type tenantEntity struct {
+	A string `db:"a,pk"`
+	T string `db:"t,tenant"`
+	B string `db:"b"`
+}
+
+type versionedEntity struct {
+	A string `db:"a,pk"`
+	B string `db:"b"`
+	V int    `db:"v,version"`
+}
+
+type softEntity struct {
+	A string     `db:"a,pk"`
+	B string     `db:"b"`
+	D *time.Time `db:"d,deleted"`
+}
+
+type stampedEntity struct {
+	A string    `db:"a,pk"`
+	B string    `db:"b"`
+	C time.Time `db:"c,created"`
+	U time.Time `db:"u,updated"`
+}
+
+type returningEntity struct {
+	A string    `db:"a,pk"`
+	B string    `db:"b"`
+	C time.Time `db:"c,returning"`
+	N int       `db:"n,generated"`
+}
+
+type serialEntity struct {
+	N int64  `db:"n,pk,generated"`
+	B string `db:"b"`
+}
+
+type trackedEntity struct {
+	Tracked
+	A string    `db:"a,pk"`
+	B []byte    `db:"b"`
+	C time.Time `db:"c"`
+	D int       `db:"d,omitempty"`
+}
+
 type syntheticEntity struct {
 	A string `db:"a,pk"`
 }
@@ -1,11 +1,126 @@
 package entity
 
 import (
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
 )
 
+// The maximum number of bind parameters that may be referenced by a single
+// statement. This is the limit imposed by the Postgres wire protocol.
+const maxParams = 65535
+
+// Statement is an SQL statement and the arguments it references.
+type Statement struct {
+	SQL  string
+	Args []interface{}
+	Rows int // the number of rows the statement writes, if known
+	// DoNothing is set for upserts which leave conflicting rows as they are,
+	// and so may affect fewer rows than they write
+	DoNothing bool
+}
+
+// GenerateConfig describes optional modifications to the statements
+// produced by a Generator.
+type GenerateConfig struct {
+	Where     *Columns         // additional equality conditions, combined with the keys
+	Conflict  Conflict         // the conflict target of an upsert; by default, the primary keys
+	DoNothing bool             // when an upsert conflicts, do nothing instead of updating
+	Merge     map[string]Merge // how columns are updated when an upsert conflicts
+}
+
+// Conflict describes the conflict target of an upsert. Either a set of
+// columns, which may be qualified by an index predicate to match a partial
+// unique index, or the name of a constraint is specified.
+type Conflict struct {
+	Columns    []string
+	Where      string // a partial index predicate, which is written verbatim
+	Constraint string
+}
+
+// Merge describes how a column of an existing row is updated when an upsert
+// conflicts with it.
+type Merge int
+
+const (
+	MergeReplace   Merge = iota // replace the existing value with the proposed one
+	MergeKeep                   // keep the existing value
+	MergeCoalesce               // replace the existing value unless the proposed one is NULL
+	MergeIncrement              // add the proposed value to the existing value
+)
+
+func (c GenerateConfig) WithOptions(opts []GenerateOption) GenerateConfig {
+	for _, f := range opts {
+		c = f(c)
+	}
+	return c
+}
+
+type GenerateOption func(GenerateConfig) GenerateConfig
+
+// WithWhere adds the provided column/value pairs as equality conditions
+// to the WHERE clause of a statement. A nil value requires that the column
+// is NULL.
+func WithWhere(cols *Columns) GenerateOption {
+	return func(c GenerateConfig) GenerateConfig {
+		if cols == nil {
+			return c
+		}
+		w := &Columns{}
+		if c.Where != nil {
+			w.Cols = append(w.Cols, c.Where.Cols...)
+			w.Vals = append(w.Vals, c.Where.Vals...)
+		}
+		w.Cols = append(w.Cols, cols.Cols...)
+		w.Vals = append(w.Vals, cols.Vals...)
+		c.Where = w
+		return c
+	}
+}
+
+// WithConflict sets the conflict target of an upsert to the provided unique
+// columns. If a predicate is provided, the target is the partial unique index
+// on those columns which has that predicate.
+func WithConflict(cols []string, where string) GenerateOption {
+	return func(c GenerateConfig) GenerateConfig {
+		c.Conflict = Conflict{Columns: cols, Where: where}
+		return c
+	}
+}
+
+// WithConflictConstraint sets the conflict target of an upsert to the named
+// constraint.
+func WithConflictConstraint(name string) GenerateOption {
+	return func(c GenerateConfig) GenerateConfig {
+		c.Conflict = Conflict{Constraint: name}
+		return c
+	}
+}
+
+// WithDoNothing causes an upsert which conflicts with an existing row to leave
+// that row as it is.
+func WithDoNothing() GenerateOption {
+	return func(c GenerateConfig) GenerateConfig {
+		c.DoNothing = true
+		return c
+	}
+}
+
+// WithMerge sets how a column is updated when an upsert conflicts with an
+// existing row.
+func WithMerge(col string, m Merge) GenerateOption {
+	return func(c GenerateConfig) GenerateConfig {
+		merge := make(map[string]Merge, len(c.Merge)+1)
+		for k, v := range c.Merge {
+			merge[k] = v
+		}
+		merge[col] = m
+		c.Merge = merge
+		return c
+	}
+}
+
 type Generator struct {
 	fm     *FieldMapper
 	sorted bool
@@ -15,20 +130,19 @@ func NewGenerator(m *FieldMapper) *Generator {
 	return &Generator{m, false}
 }
 
-func (g *Generator) Select(table string, entity interface{}, keys *Columns) (string, []interface{}) {
+func (g *Generator) Select(table string, entity interface{}, keys *Columns, opts ...GenerateOption) (string, []interface{}) {
+	conf := GenerateConfig{}.WithOptions(opts)
 	_, cols := g.fm.Columns(entity)
 	if g.sorted {
 		sort.Sort(cols)
 		sort.Sort(keys)
 	}
 
-	var n, x int
 	args := make([]interface{}, 0, len(keys.Vals))
 
 	b := &strings.Builder{}
 	b.WriteString("SELECT ")
 
-	n = 0
 	for i, e := range cols.Cols {
 		if i > 0 {
 			b.WriteString(", ")
@@ -39,25 +153,57 @@ func (g *Generator) Select(table string, entity interface{}, keys *Columns) (str
 	b.WriteString(" FROM ")
 	b.WriteString(table)
 	b.WriteString(" WHERE ")
+	args = writeConditions(b, "", args, keys, conf.Where)
 
-	n = 0
-	for i, e := range keys.Cols {
-		if n > 0 {
-			b.WriteString(" AND ")
-		}
-		b.WriteString(e)
-		b.WriteString(" = $")
-		b.WriteString(strconv.FormatInt(int64(x+1), 10))
-		args = append(args, keys.Vals[i])
-		x++
-		n++
+	return b.String(), args
+}
+
+// Where produces a WHERE clause for the conditions provided via WithWhere,
+// and the arguments it references. If there are no conditions, the clause
+// is empty.
+func (g *Generator) Where(opts ...GenerateOption) (string, []interface{}) {
+	conf := GenerateConfig{}.WithOptions(opts)
+	if conf.Where == nil || len(conf.Where.Cols) == 0 {
+		return "", nil
+	}
+
+	args := make([]interface{}, 0, len(conf.Where.Vals))
+
+	b := &strings.Builder{}
+	b.WriteString("WHERE ")
+	args = writeConditions(b, "", args, conf.Where)
+
+	return b.String(), args
+}
+
+// Exists produces a statement which determines whether a row identified by
+// the provided keys exists in a table. The statement yields a single boolean.
+func (g *Generator) Exists(table string, keys *Columns, opts ...GenerateOption) (string, []interface{}) {
+	conf := GenerateConfig{}.WithOptions(opts)
+	if g.sorted {
+		sort.Sort(keys)
 	}
 
+	args := make([]interface{}, 0, len(keys.Vals))
+
+	b := &strings.Builder{}
+	b.WriteString("SELECT EXISTS (SELECT 1 FROM ")
+	b.WriteString(table)
+	b.WriteString(" WHERE ")
+	args = writeConditions(b, "", args, keys, conf.Where)
+	b.WriteString(")")
+
 	return b.String(), args
 }
 
+// Insert produces a statement which inserts an entity. Generated columns
+// are omitted, except for generated primary keys the entity provides a
+// value for. If the entity declares returning columns, they are returned by
+// the statement.
 func (g *Generator) Insert(table string, entity interface{}) (string, []interface{}) {
+	typ := reflect.TypeOf(entity)
 	_, cols := g.fm.Columns(entity)
+	cols = omitGenerated(cols, setOf(g.fm.GeneratedForType(typ)), setOf(g.fm.KeysForType(typ)))
 	if g.sorted {
 		sort.Sort(cols)
 	}
@@ -85,27 +231,141 @@ func (g *Generator) Insert(table string, entity interface{}) (string, []interfac
 	}
 
 	b.WriteString(")")
+	writeReturning(b, g.fm.ReturningForType(typ))
 
 	return b.String(), cols.Vals
 }
 
-func (g *Generator) Upsert(table string, entity interface{}, names []string) (string, []interface{}) {
-	keys, cols := g.fm.Columns(entity)
-	if g.sorted {
-		sort.Sort(keys)
-		sort.Sort(cols)
+// InsertMany produces statements which insert a set of entities using
+// multi-row INSERTs. As many rows are included in each statement as is
+// possible without exceeding the bind parameter limit, so more than one
+// statement is produced for large sets. Columns are the union of those
+// produced by every entity; see FieldMapper.Tabulate.
+func (g *Generator) InsertMany(table string, entities []interface{}) []Statement {
+	cols, rows := g.tabulate(entities)
+	return g.insertMany(table, cols, rows, 0, nil)
+}
+
+// UpsertMany produces statements which upsert a set of entities using
+// multi-row INSERTs, batched in the same manner as InsertMany. Conflicts are
+// handled as they are by Upsert.
+//
+// Postgres does not permit a statement to update the same row twice, so a
+// set of entities should not contain more than one entity with a given key.
+func (g *Generator) UpsertMany(table string, entities []interface{}, opts ...GenerateOption) []Statement {
+	conf := GenerateConfig{}.WithOptions(opts)
+	cols, rows := g.tabulate(entities)
+	if len(rows) == 0 {
+		return nil
 	}
 
-	kset := make(map[string]struct{})
-	for _, k := range keys.Cols {
-		kset[k] = struct{}{}
+	typ := reflect.TypeOf(entities[0])
+	keys := g.fm.KeysForType(typ)
+	sort.Strings(keys)
+
+	var reserve int
+	if conf.Where != nil {
+		reserve = len(conf.Where.Cols)
 	}
 
-	ucols := make(map[string]int)
-	for i, e := range cols.Cols {
-		if _, ok := kset[e]; !ok {
-			ucols[e] = i
+	return g.insertMany(table, cols, rows, reserve, func(b *strings.Builder, args []interface{}) ([]interface{}, bool) {
+		return g.writeOnConflict(b, table, typ, cols, keys, nil, conf, args)
+	})
+}
+
+// tabulate produces the columns and rows written by a multi-row insert of a
+// set of entities, which excludes generated columns.
+func (g *Generator) tabulate(entities []interface{}) ([]string, [][]interface{}) {
+	cols, rows := g.fm.Tabulate(entities)
+	if len(rows) == 0 {
+		return cols, rows
+	}
+	typ := reflect.TypeOf(entities[0])
+	return omitGeneratedRows(cols, rows, setOf(g.fm.GeneratedForType(typ)), setOf(g.fm.KeysForType(typ)))
+}
+
+// insertMany produces batched multi-row INSERT statements for the provided
+// rows. Each statement leaves room for the number of reserved parameters,
+// which may be referenced by a suffix that is appended to every statement.
+func (g *Generator) insertMany(table string, cols []string, rows [][]interface{}, reserve int, suffix func(*strings.Builder, []interface{}) ([]interface{}, bool)) []Statement {
+	if len(cols) == 0 || len(rows) == 0 {
+		return nil
+	}
+
+	per := (maxParams - reserve) / len(cols)
+	stmts := make([]Statement, 0, (len(rows)+per-1)/per)
+
+	for len(rows) > 0 {
+		n := per
+		if n > len(rows) {
+			n = len(rows)
+		}
+		batch := rows[:n]
+		rows = rows[n:]
+
+		args := make([]interface{}, 0, len(batch)*len(cols))
+
+		b := &strings.Builder{}
+		b.WriteString("INSERT INTO ")
+		b.WriteString(table)
+		b.WriteString(" (")
+
+		for i, e := range cols {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(e)
+		}
+
+		b.WriteString(") VALUES ")
+
+		for i, row := range batch {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString("(")
+			for j, v := range row {
+				if j > 0 {
+					b.WriteString(", ")
+				}
+				b.WriteString("$")
+				b.WriteString(strconv.FormatInt(int64(len(args)+1), 10))
+				args = append(args, v)
+			}
+			b.WriteString(")")
+		}
+
+		var nothing bool
+		if suffix != nil {
+			args, nothing = suffix(b, args)
 		}
+
+		stmts = append(stmts, Statement{SQL: b.String(), Args: args, Rows: len(batch), DoNothing: nothing})
+	}
+
+	return stmts
+}
+
+// Upsert produces a statement which inserts an entity or, if it conflicts
+// with an existing row, updates that row instead. By default, the conflict
+// target is the primary key and every other column is replaced by the value
+// proposed for it. If names are provided, only those columns are updated.
+// Version columns are incremented, and neither creation time nor generated
+// columns are updated. Conditions provided via WithWhere restrict which
+// existing rows may be updated. Since an existing row keeps its creation
+// time, creation time columns are returned along with any returning columns
+// the entity declares.
+//
+// See WithConflict, WithConflictConstraint, WithDoNothing, and WithMerge for
+// other ways of handling a conflict.
+func (g *Generator) Upsert(table string, entity interface{}, names []string, opts ...GenerateOption) (string, []interface{}) {
+	conf := GenerateConfig{}.WithOptions(opts)
+	typ := reflect.TypeOf(entity)
+	keys, cols := g.fm.Columns(entity)
+	cols = omitGenerated(cols, setOf(g.fm.GeneratedForType(typ)), setOf(keys.Cols))
+	if g.sorted {
+		sort.Sort(keys)
+		sort.Sort(cols)
 	}
 
 	b := &strings.Builder{}
@@ -130,32 +390,119 @@ func (g *Generator) Upsert(table string, entity interface{}, names []string) (st
 		b.WriteString(strconv.FormatInt(int64(i+1), 10))
 	}
 
-	b.WriteString(") ON CONFLICT (")
+	b.WriteString(")")
 
-	for i, e := range keys.Cols {
-		if i > 0 {
-			b.WriteString(", ")
+	args := append(make([]interface{}, 0, len(cols.Vals)), cols.Vals...)
+	args, _ = g.writeOnConflict(b, table, typ, cols.Cols, keys.Cols, names, conf, args)
+	writeReturning(b, g.upsertReturning(typ))
+
+	return b.String(), args
+}
+
+// upsertReturning produces the columns returned by an upsert of an entity
+// type: its returning columns and its creation time columns.
+func (g *Generator) upsertReturning(typ reflect.Type) []string {
+	cols := g.fm.ReturningForType(typ)
+	rset := setOf(cols)
+	for _, e := range g.fm.CreatedForType(typ) {
+		if _, ok := rset[e]; !ok {
+			cols = append(cols, e)
 		}
-		b.WriteString(e)
+	}
+	return cols
+}
+
+// writeOnConflict writes the ON CONFLICT clause of an upsert which inserts
+// the provided columns of an entity type and appends the values of any
+// conditions it references to args. It reports whether the clause is DO
+// NOTHING, which is the case when there is nothing to update.
+func (g *Generator) writeOnConflict(b *strings.Builder, table string, typ reflect.Type, cols, keys, names []string, conf GenerateConfig, args []interface{}) ([]interface{}, bool) {
+	b.WriteString(" ON CONFLICT ")
+	target := keys
+	if c := conf.Conflict; c.Constraint != "" {
+		b.WriteString("ON CONSTRAINT ")
+		b.WriteString(c.Constraint)
+	} else {
+		if len(c.Columns) > 0 {
+			target = c.Columns
+		}
+		b.WriteString("(")
+		for i, e := range target {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(e)
+		}
+		b.WriteString(")")
+		if c.Where != "" {
+			b.WriteString(" WHERE ")
+			b.WriteString(c.Where)
+		}
+	}
+
+	if conf.DoNothing {
+		b.WriteString(" DO NOTHING")
+		return args, true
 	}
 
-	b.WriteString(") DO UPDATE SET ")
+	// neither keys, the conflict target, creation times, nor generated
+	// columns are changed by an update
+	fixed := setOf(keys, target, g.fm.CreatedForType(typ), g.fm.GeneratedForType(typ))
+	vset := setOf(g.fm.VersionForType(typ))
+	var incl map[string]struct{}
+	if len(names) > 0 {
+		incl = setOf(names)
+	}
 
 	var n int
-	for e, i := range ucols {
-		if n > 0 {
+	for _, e := range cols {
+		if _, ok := fixed[e]; ok {
+			continue
+		}
+		_, version := vset[e]
+		if !version && incl != nil {
+			if _, ok := incl[e]; !ok {
+				continue
+			}
+		}
+		merge := conf.Merge[e]
+		if !version && merge == MergeKeep {
+			continue
+		}
+		if n == 0 {
+			b.WriteString(" DO UPDATE SET ")
+		} else {
 			b.WriteString(", ")
 		}
-		b.WriteString(e)
-		b.WriteString(" = $")
-		b.WriteString(strconv.FormatInt(int64(i+1), 10))
+		if version {
+			writeIncrement(b, table, e)
+		} else {
+			writeMerge(b, table, e, merge)
+		}
 		n++
 	}
+	if n == 0 {
+		b.WriteString(" DO NOTHING")
+		return args, true
+	}
 
-	return b.String(), cols.Vals
+	if conf.Where != nil && len(conf.Where.Cols) > 0 {
+		// conditions must be qualified, since both the existing row and the
+		// proposed row (EXCLUDED) are in scope for an upsert
+		b.WriteString(" WHERE ")
+		args = writeConditions(b, table, args, conf.Where)
+	}
+
+	return args, false
 }
 
-func (g *Generator) Update(table string, entity interface{}, names []string) (string, []interface{}) {
+// Update produces a statement which updates the named columns of an entity,
+// or every column if none are named. Creation time and generated columns are
+// not updated. If the entity declares a version column it is incremented and
+// the row is only matched if its version is unchanged. If the entity declares
+// returning columns, they are returned by the statement.
+func (g *Generator) Update(table string, entity interface{}, names []string, opts ...GenerateOption) (string, []interface{}) {
+	conf := GenerateConfig{}.WithOptions(opts)
 	keys, cols := g.fm.Columns(entity)
 	if g.sorted {
 		sort.Sort(keys)
@@ -170,6 +517,11 @@ func (g *Generator) Update(table string, entity interface{}, names []string) (st
 		}
 	}
 
+	typ := reflect.TypeOf(entity)
+	vers := g.fm.VersionForType(typ)
+	vset := setOf(vers)
+	cset := setOf(g.fm.CreatedForType(typ), g.fm.GeneratedForType(typ))
+
 	var n, x int
 	b := &strings.Builder{}
 	b.WriteString("UPDATE ")
@@ -178,8 +530,19 @@ func (g *Generator) Update(table string, entity interface{}, names []string) (st
 
 	args := make([]interface{}, 0, len(incl))
 
+	// version columns are never assigned; they are incremented and the row
+	// is only updated if its version is still the one the entity was read at
+	vcond := &Columns{}
 	n = 0
 	for i, e := range cols.Cols {
+		if _, ok := vset[e]; ok {
+			vcond.Cols = append(vcond.Cols, e)
+			vcond.Vals = append(vcond.Vals, cols.Vals[i])
+			continue
+		}
+		if _, ok := cset[e]; ok {
+			continue // creation times and generated columns are never updated
+		}
 		if incl != nil {
 			if _, ok := incl[e]; !ok {
 				continue
@@ -195,50 +558,232 @@ func (g *Generator) Update(table string, entity interface{}, names []string) (st
 		n++
 		x++
 	}
+	for _, e := range vers {
+		if n > 0 {
+			b.WriteString(", ")
+		}
+		writeIncrement(b, "", e)
+		n++
+	}
 
 	b.WriteString(" WHERE ")
+	args = writeConditions(b, "", args, keys, conf.Where, vcond)
+	writeReturning(b, g.fm.ReturningForType(typ))
 
-	n = 0
-	for i, e := range keys.Cols {
-		if n > 0 {
-			b.WriteString(" AND ")
+	return b.String(), args
+}
+
+// Assign produces a statement which sets columns of the row identified by
+// the provided keys to the values provided, regardless of the entity that
+// is mapped to the row.
+func (g *Generator) Assign(table string, set, keys *Columns, opts ...GenerateOption) (string, []interface{}) {
+	conf := GenerateConfig{}.WithOptions(opts)
+	if g.sorted {
+		sort.Sort(set)
+		sort.Sort(keys)
+	}
+
+	args := make([]interface{}, 0, len(set.Vals)+len(keys.Vals))
+
+	b := &strings.Builder{}
+	b.WriteString("UPDATE ")
+	b.WriteString(table)
+	b.WriteString(" SET ")
+
+	for i, e := range set.Cols {
+		if i > 0 {
+			b.WriteString(", ")
 		}
 		b.WriteString(e)
 		b.WriteString(" = $")
-		b.WriteString(strconv.FormatInt(int64(x+1), 10))
-		args = append(args, keys.Vals[i])
-		x++
-		n++
+		b.WriteString(strconv.FormatInt(int64(len(args)+1), 10))
+		args = append(args, set.Vals[i])
 	}
 
+	b.WriteString(" WHERE ")
+	args = writeConditions(b, "", args, keys, conf.Where)
+
 	return b.String(), args
 }
 
-func (g *Generator) Delete(table string, keys *Columns) (string, []interface{}) {
+func (g *Generator) Delete(table string, keys *Columns, opts ...GenerateOption) (string, []interface{}) {
+	conf := GenerateConfig{}.WithOptions(opts)
 	if g.sorted {
 		sort.Sort(keys)
 	}
 
-	var n, x int
 	args := make([]interface{}, 0, len(keys.Vals))
 
 	b := &strings.Builder{}
 	b.WriteString("DELETE FROM ")
 	b.WriteString(table)
 	b.WriteString(" WHERE ")
+	args = writeConditions(b, "", args, keys, conf.Where)
 
-	n = 0
-	for i, e := range keys.Cols {
-		if n > 0 {
-			b.WriteString(" AND ")
+	return b.String(), args
+}
+
+// omitGenerated produces the subset of columns which excludes generated
+// columns. The database always assigns such columns, so they are never
+// written, with the exception of generated primary keys, which are written
+// when the entity provides a value for them.
+func omitGenerated(cols *Columns, gen, keys map[string]struct{}) *Columns {
+	if len(gen) == 0 {
+		return cols
+	}
+	res := &Columns{
+		Cols: make([]string, 0, len(cols.Cols)),
+		Vals: make([]interface{}, 0, len(cols.Vals)),
+	}
+	for i, e := range cols.Cols {
+		if _, ok := gen[e]; ok {
+			if _, key := keys[e]; !key || isZero(cols.Vals[i]) {
+				continue
+			}
+		}
+		res.Cols = append(res.Cols, e)
+		res.Vals = append(res.Vals, cols.Vals[i])
+	}
+	return res
+}
+
+// omitGeneratedRows removes generated columns from tabulated rows in the
+// manner of omitGenerated. A generated primary key is only written if every
+// row provides a value for it.
+func omitGeneratedRows(cols []string, rows [][]interface{}, gen, keys map[string]struct{}) ([]string, [][]interface{}) {
+	if len(gen) == 0 {
+		return cols, rows
+	}
+	var incl []int
+outer:
+	for i, e := range cols {
+		if _, ok := gen[e]; ok {
+			if _, key := keys[e]; !key {
+				continue
+			}
+			for _, r := range rows {
+				if isZero(r[i]) {
+					continue outer
+				}
+			}
+		}
+		incl = append(incl, i)
+	}
+	if len(incl) == len(cols) {
+		return cols, rows
+	}
+	rcols := make([]string, len(incl))
+	for i, x := range incl {
+		rcols[i] = cols[x]
+	}
+	rrows := make([][]interface{}, len(rows))
+	for i, r := range rows {
+		rr := make([]interface{}, len(incl))
+		for j, x := range incl {
+			rr[j] = r[x]
+		}
+		rrows[i] = rr
+	}
+	return rcols, rrows
+}
+
+func isZero(v interface{}) bool {
+	return v == nil || reflect.ValueOf(v).IsZero()
+}
+
+// writeReturning writes a RETURNING clause for the provided columns, if
+// there are any.
+func writeReturning(b *strings.Builder, cols []string) {
+	for i, e := range cols {
+		if i == 0 {
+			b.WriteString(" RETURNING ")
+		} else {
+			b.WriteString(", ")
 		}
 		b.WriteString(e)
-		b.WriteString(" = $")
-		b.WriteString(strconv.FormatInt(int64(x+1), 10))
-		args = append(args, keys.Vals[i])
-		x++
-		n++
 	}
+}
 
-	return b.String(), args
+// setOf produces a set containing every element of the provided lists.
+func setOf(lists ...[]string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, l := range lists {
+		for _, e := range l {
+			set[e] = struct{}{}
+		}
+	}
+	return set
+}
+
+// writeMerge writes an assignment which updates a column of an existing row
+// from the proposed row of an upsert, which is named EXCLUDED.
+func writeMerge(b *strings.Builder, table, col string, m Merge) {
+	b.WriteString(col)
+	b.WriteString(" = ")
+	switch m {
+	case MergeCoalesce:
+		b.WriteString("COALESCE(EXCLUDED.")
+		b.WriteString(col)
+		b.WriteString(", ")
+		b.WriteString(table)
+		b.WriteString(".")
+		b.WriteString(col)
+		b.WriteString(")")
+	case MergeIncrement:
+		b.WriteString(table)
+		b.WriteString(".")
+		b.WriteString(col)
+		b.WriteString(" + EXCLUDED.")
+		b.WriteString(col)
+	default:
+		b.WriteString("EXCLUDED.")
+		b.WriteString(col)
+	}
+}
+
+// writeIncrement writes an assignment which increments a column by one. If
+// a qualifier is provided, the column it is incremented from is prefixed by
+// it, which is necessary when the column would otherwise be ambiguous.
+func writeIncrement(b *strings.Builder, qual, col string) {
+	b.WriteString(col)
+	b.WriteString(" = ")
+	if qual != "" {
+		b.WriteString(qual)
+		b.WriteString(".")
+	}
+	b.WriteString(col)
+	b.WriteString(" + 1")
+}
+
+// writeConditions writes equality conditions for every column in each set,
+// joined by AND, and appends their values to args. Placeholders are numbered
+// following the arguments that have already been accumulated. A nil value,
+// which could never be equal to anything, produces an IS NULL condition. If
+// a qualifier is provided, each column is prefixed by it.
+func writeConditions(b *strings.Builder, qual string, args []interface{}, sets ...*Columns) []interface{} {
+	var n int
+	for _, set := range sets {
+		if set == nil {
+			continue
+		}
+		for i, e := range set.Cols {
+			if n > 0 {
+				b.WriteString(" AND ")
+			}
+			if qual != "" {
+				b.WriteString(qual)
+				b.WriteString(".")
+			}
+			b.WriteString(e)
+			if set.Vals[i] == nil {
+				b.WriteString(" IS NULL")
+			} else {
+				b.WriteString(" = $")
+				b.WriteString(strconv.FormatInt(int64(len(args)+1), 10))
+				args = append(args, set.Vals[i])
+			}
+			n++
+		}
+	}
+	return args
 }
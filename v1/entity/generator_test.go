@@ -3,6 +3,7 @@ package entity
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -20,6 +21,30 @@ func TestGeneratorInsert(t *testing.T) {
 			"INSERT INTO some_table (e, y, z) VALUES ($1, $2, $3)",
 			[]interface{}{nil, "BBB", "AAA"},
 		},
+		{
+			returningEntity{"AAA", "BBB", time.Time{}, 0},
+			"some_table",
+			"INSERT INTO some_table (a, b, c) VALUES ($1, $2, $3) RETURNING c, n",
+			[]interface{}{"AAA", "BBB", time.Time{}},
+		},
+		{
+			returningEntity{"AAA", "BBB", time.Time{}, 5}, // generated columns are never written
+			"some_table",
+			"INSERT INTO some_table (a, b, c) VALUES ($1, $2, $3) RETURNING c, n",
+			[]interface{}{"AAA", "BBB", time.Time{}},
+		},
+		{
+			serialEntity{0, "BBB"},
+			"some_table",
+			"INSERT INTO some_table (b) VALUES ($1) RETURNING n",
+			[]interface{}{"BBB"},
+		},
+		{
+			serialEntity{5, "BBB"}, // ...except for generated keys that have a value
+			"some_table",
+			"INSERT INTO some_table (b, n) VALUES ($1, $2) RETURNING n",
+			[]interface{}{"BBB", int64(5)},
+		},
 	}
 	gen := &Generator{NewFieldMapper(), true}
 	for _, e := range tests {
@@ -30,11 +55,331 @@ func TestGeneratorInsert(t *testing.T) {
 	}
 }
 
+func TestGeneratorInsertMany(t *testing.T) {
+	tests := []struct {
+		Entities []interface{}
+		Table    string
+		Expect   []Statement
+	}{
+		{
+			nil,
+			"some_table",
+			nil,
+		},
+		{
+			[]interface{}{
+				testEntity{embedEntity{"BBB"}, "AAA", 999, 0},
+			},
+			"some_table",
+			[]Statement{
+				{"INSERT INTO some_table (e, y, z) VALUES ($1, $2, $3)", []interface{}{nil, "BBB", "AAA"}, 1, false},
+			},
+		},
+		{
+			[]interface{}{
+				testEntity{embedEntity{"BBB"}, "AAA", 999, 0},
+				&testEntity{embedEntity{"DDD"}, "CCC", 999, 111},
+			},
+			"some_table",
+			[]Statement{
+				{"INSERT INTO some_table (e, y, z) VALUES ($1, $2, $3), ($4, $5, $6)", []interface{}{nil, "BBB", "AAA", 111, "DDD", "CCC"}, 2, false},
+			},
+		},
+		{
+			[]interface{}{
+				returningEntity{"AAA", "BBB", time.Time{}, 5},
+				returningEntity{"CCC", "DDD", time.Time{}, 0},
+			},
+			"some_table",
+			[]Statement{
+				{"INSERT INTO some_table (a, b, c) VALUES ($1, $2, $3), ($4, $5, $6)", []interface{}{"AAA", "BBB", time.Time{}, "CCC", "DDD", time.Time{}}, 2, false},
+			},
+		},
+		{
+			[]interface{}{
+				serialEntity{5, "BBB"},
+				serialEntity{0, "CCC"},
+			},
+			"some_table",
+			[]Statement{
+				{"INSERT INTO some_table (b) VALUES ($1), ($2)", []interface{}{"BBB", "CCC"}, 2, false},
+			},
+		},
+	}
+	gen := &Generator{NewFieldMapper(), true}
+	for _, e := range tests {
+		stmts := gen.InsertMany(e.Table, e.Entities)
+		for _, s := range stmts {
+			fmt.Println("-->", s.SQL)
+		}
+		assert.Equal(t, e.Expect, stmts)
+	}
+}
+
+func TestGeneratorInsertManyBatches(t *testing.T) {
+	gen := &Generator{NewFieldMapper(), true}
+	per := maxParams / 3 // testEntity produces three columns
+
+	ents := make([]interface{}, per+1)
+	for i := range ents {
+		ents[i] = testEntity{embedEntity{"BBB"}, fmt.Sprint(i), 0, i}
+	}
+
+	stmts := gen.InsertMany("some_table", ents)
+	if assert.Len(t, stmts, 2) {
+		assert.Len(t, stmts[0].Args, per*3)
+		assert.Equal(t, per, stmts[0].Rows)
+		assert.Equal(t, "INSERT INTO some_table (e, y, z) VALUES ($1, $2, $3)", stmts[1].SQL)
+		assert.Equal(t, []interface{}{per, "BBB", fmt.Sprint(per)}, stmts[1].Args)
+	}
+}
+
+func TestGeneratorUpsertMany(t *testing.T) {
+	tests := []struct {
+		Entities []interface{}
+		Table    string
+		Options  []GenerateOption
+		Expect   []Statement
+	}{
+		{
+			nil,
+			"some_table",
+			nil,
+			nil,
+		},
+		{
+			[]interface{}{
+				testEntity{embedEntity{"BBB"}, "AAA", 999, 0},
+				&testEntity{embedEntity{"DDD"}, "CCC", 999, 111},
+			},
+			"some_table",
+			nil,
+			[]Statement{
+				{
+					"INSERT INTO some_table (e, y, z) VALUES ($1, $2, $3), ($4, $5, $6) ON CONFLICT (z) DO UPDATE SET e = EXCLUDED.e, y = EXCLUDED.y",
+					[]interface{}{nil, "BBB", "AAA", 111, "DDD", "CCC"},
+					2,
+					false,
+				},
+			},
+		},
+		{
+			[]interface{}{
+				testEntity{embedEntity{"BBB"}, "AAA", 999, 0},
+				&testEntity{embedEntity{"DDD"}, "CCC", 999, 111},
+			},
+			"some_table",
+			[]GenerateOption{WithMerge("e", MergeIncrement), WithMerge("y", MergeKeep)},
+			[]Statement{
+				{
+					"INSERT INTO some_table (e, y, z) VALUES ($1, $2, $3), ($4, $5, $6) ON CONFLICT (z) DO UPDATE SET e = some_table.e + EXCLUDED.e",
+					[]interface{}{nil, "BBB", "AAA", 111, "DDD", "CCC"},
+					2,
+					false,
+				},
+			},
+		},
+		{
+			[]interface{}{
+				testEntity{embedEntity{"BBB"}, "AAA", 999, 0},
+				&testEntity{embedEntity{"DDD"}, "CCC", 999, 111},
+			},
+			"some_table",
+			[]GenerateOption{WithMerge("e", MergeKeep), WithMerge("y", MergeKeep)},
+			[]Statement{
+				{
+					"INSERT INTO some_table (e, y, z) VALUES ($1, $2, $3), ($4, $5, $6) ON CONFLICT (z) DO NOTHING",
+					[]interface{}{nil, "BBB", "AAA", 111, "DDD", "CCC"},
+					2,
+					true, // nothing remains to be updated
+				},
+			},
+		},
+		{
+			[]interface{}{
+				tenantEntity{"AAA", "TTT", "BBB"},
+				tenantEntity{"CCC", "TTT", "DDD"},
+			},
+			"some_table",
+			[]GenerateOption{WithWhere(&Columns{Cols: []string{"t"}, Vals: []interface{}{"TTT"}})},
+			[]Statement{
+				{
+					"INSERT INTO some_table (a, b, t) VALUES ($1, $2, $3), ($4, $5, $6) ON CONFLICT (a) DO UPDATE SET b = EXCLUDED.b, t = EXCLUDED.t WHERE some_table.t = $7",
+					[]interface{}{"AAA", "BBB", "TTT", "CCC", "DDD", "TTT", "TTT"},
+					2,
+					false,
+				},
+			},
+		},
+		{
+			[]interface{}{
+				multiPKEntity{embedEntity{"BBB"}, "AAA", "CCC"},
+			},
+			"some_table",
+			nil,
+			[]Statement{
+				{
+					"INSERT INTO some_table (x, y, z) VALUES ($1, $2, $3) ON CONFLICT (x, z) DO UPDATE SET y = EXCLUDED.y",
+					[]interface{}{"CCC", "BBB", "AAA"},
+					1,
+					false,
+				},
+			},
+		},
+		{
+			[]interface{}{
+				versionedEntity{"AAA", "BBB", 1},
+			},
+			"some_table",
+			nil,
+			[]Statement{
+				{
+					"INSERT INTO some_table (a, b, v) VALUES ($1, $2, $3) ON CONFLICT (a) DO UPDATE SET b = EXCLUDED.b, v = some_table.v + 1",
+					[]interface{}{"AAA", "BBB", 1},
+					1,
+					false,
+				},
+			},
+		},
+		{
+			[]interface{}{
+				stampedEntity{"AAA", "BBB", time.Time{}, time.Time{}},
+			},
+			"some_table",
+			nil,
+			[]Statement{
+				{
+					"INSERT INTO some_table (a, b, c, u) VALUES ($1, $2, $3, $4) ON CONFLICT (a) DO UPDATE SET b = EXCLUDED.b, u = EXCLUDED.u",
+					[]interface{}{"AAA", "BBB", time.Time{}, time.Time{}},
+					1,
+					false,
+				},
+			},
+		},
+	}
+	gen := &Generator{NewFieldMapper(), true}
+	for _, e := range tests {
+		stmts := gen.UpsertMany(e.Table, e.Entities, e.Options...)
+		for _, s := range stmts {
+			fmt.Println("-->", s.SQL)
+		}
+		assert.Equal(t, e.Expect, stmts)
+	}
+}
+
+func TestGeneratorUpsert(t *testing.T) {
+	tests := []struct {
+		Entity  interface{}
+		Table   string
+		Columns []string
+		Options []GenerateOption
+		SQL     string
+		Args    []interface{}
+	}{
+		{
+			testEntity{embedEntity{"BBB"}, "AAA", 999, 0},
+			"some_table",
+			nil,
+			nil,
+			"INSERT INTO some_table (e, y, z) VALUES ($1, $2, $3) ON CONFLICT (z) DO UPDATE SET e = EXCLUDED.e, y = EXCLUDED.y",
+			[]interface{}{nil, "BBB", "AAA"},
+		},
+		{
+			testEntity{embedEntity{"BBB"}, "AAA", 999, 0},
+			"some_table",
+			[]string{"y"},
+			nil,
+			"INSERT INTO some_table (e, y, z) VALUES ($1, $2, $3) ON CONFLICT (z) DO UPDATE SET y = EXCLUDED.y",
+			[]interface{}{nil, "BBB", "AAA"},
+		},
+		{
+			testEntity{embedEntity{"BBB"}, "AAA", 999, 0},
+			"some_table",
+			nil,
+			[]GenerateOption{WithDoNothing()},
+			"INSERT INTO some_table (e, y, z) VALUES ($1, $2, $3) ON CONFLICT (z) DO NOTHING",
+			[]interface{}{nil, "BBB", "AAA"},
+		},
+		{
+			testEntity{embedEntity{"BBB"}, "AAA", 999, 0},
+			"some_table",
+			nil,
+			[]GenerateOption{WithConflict([]string{"y"}, "e IS NOT NULL"), WithMerge("e", MergeCoalesce)},
+			"INSERT INTO some_table (e, y, z) VALUES ($1, $2, $3) ON CONFLICT (y) WHERE e IS NOT NULL DO UPDATE SET e = COALESCE(EXCLUDED.e, some_table.e)",
+			[]interface{}{nil, "BBB", "AAA"},
+		},
+		{
+			testEntity{embedEntity{"BBB"}, "AAA", 999, 0},
+			"some_table",
+			nil,
+			[]GenerateOption{WithConflictConstraint("some_table_y_key"), WithMerge("e", MergeIncrement), WithMerge("y", MergeKeep)},
+			"INSERT INTO some_table (e, y, z) VALUES ($1, $2, $3) ON CONFLICT ON CONSTRAINT some_table_y_key DO UPDATE SET e = some_table.e + EXCLUDED.e",
+			[]interface{}{nil, "BBB", "AAA"},
+		},
+		{
+			testEntity{embedEntity{"BBB"}, "AAA", 999, 0},
+			"some_table",
+			nil,
+			[]GenerateOption{WithMerge("e", MergeKeep), WithMerge("y", MergeKeep)},
+			"INSERT INTO some_table (e, y, z) VALUES ($1, $2, $3) ON CONFLICT (z) DO NOTHING",
+			[]interface{}{nil, "BBB", "AAA"},
+		},
+		{
+			tenantEntity{"AAA", "TTT", "BBB"},
+			"some_table",
+			nil,
+			[]GenerateOption{WithWhere(&Columns{Cols: []string{"t"}, Vals: []interface{}{"TTT"}})},
+			"INSERT INTO some_table (a, b, t) VALUES ($1, $2, $3) ON CONFLICT (a) DO UPDATE SET b = EXCLUDED.b, t = EXCLUDED.t WHERE some_table.t = $4",
+			[]interface{}{"AAA", "BBB", "TTT", "TTT"},
+		},
+		{
+			versionedEntity{"AAA", "BBB", 3},
+			"some_table",
+			[]string{"b"},
+			nil,
+			"INSERT INTO some_table (a, b, v) VALUES ($1, $2, $3) ON CONFLICT (a) DO UPDATE SET b = EXCLUDED.b, v = some_table.v + 1",
+			[]interface{}{"AAA", "BBB", 3},
+		},
+		{
+			returningEntity{"AAA", "BBB", time.Time{}, 0},
+			"some_table",
+			nil,
+			nil,
+			"INSERT INTO some_table (a, b, c) VALUES ($1, $2, $3) ON CONFLICT (a) DO UPDATE SET b = EXCLUDED.b, c = EXCLUDED.c RETURNING c, n",
+			[]interface{}{"AAA", "BBB", time.Time{}},
+		},
+		{
+			stampedEntity{"AAA", "BBB", time.Time{}, time.Time{}},
+			"some_table",
+			nil,
+			nil,
+			"INSERT INTO some_table (a, b, c, u) VALUES ($1, $2, $3, $4) ON CONFLICT (a) DO UPDATE SET b = EXCLUDED.b, u = EXCLUDED.u RETURNING c",
+			[]interface{}{"AAA", "BBB", time.Time{}, time.Time{}},
+		},
+	}
+	gen := &Generator{NewFieldMapper(), true}
+	for _, e := range tests {
+		sql, args := gen.Upsert(e.Table, e.Entity, e.Columns, e.Options...)
+		fmt.Println("-->", sql)
+		assert.Equal(t, e.SQL, sql)
+		assert.Equal(t, e.Args, args)
+	}
+
+	// without sorting, columns are produced in declaration order
+	gen = NewGenerator(NewFieldMapper())
+	for i := 0; i < 10; i++ {
+		sql, args := gen.Upsert("some_table", tenantEntity{"AAA", "TTT", "BBB"}, nil)
+		assert.Equal(t, "INSERT INTO some_table (a, t, b) VALUES ($1, $2, $3) ON CONFLICT (a) DO UPDATE SET t = EXCLUDED.t, b = EXCLUDED.b", sql)
+		assert.Equal(t, []interface{}{"AAA", "TTT", "BBB"}, args)
+	}
+}
+
 func TestGeneratorUpdate(t *testing.T) {
 	tests := []struct {
 		Entity  interface{}
 		Table   string
 		Columns []string
+		Options []GenerateOption
 		SQL     string
 		Args    []interface{}
 	}{
@@ -42,6 +387,7 @@ func TestGeneratorUpdate(t *testing.T) {
 			testEntity{embedEntity{"BBB"}, "AAA", 999, 0},
 			"some_table",
 			[]string{"z", "y"},
+			nil,
 			"UPDATE some_table SET y = $1, z = $2 WHERE z = $3",
 			[]interface{}{"BBB", "AAA", "AAA"},
 		},
@@ -49,6 +395,7 @@ func TestGeneratorUpdate(t *testing.T) {
 			testEntity{embedEntity{"BBB"}, "AAA", 999, 0},
 			"some_table",
 			nil,
+			nil,
 			"UPDATE some_table SET e = $1, y = $2, z = $3 WHERE z = $4",
 			[]interface{}{nil, "BBB", "AAA", "AAA"},
 		},
@@ -56,6 +403,7 @@ func TestGeneratorUpdate(t *testing.T) {
 			testEntity{embedEntity{"BBB"}, "AAA", 999, 0},
 			"some_table",
 			[]string{"z"},
+			nil,
 			"UPDATE some_table SET z = $1 WHERE z = $2",
 			[]interface{}{"AAA", "AAA"},
 		},
@@ -63,13 +411,54 @@ func TestGeneratorUpdate(t *testing.T) {
 			multiPKEntity{embedEntity{"BBB"}, "AAA", "CCC"},
 			"some_table",
 			[]string{"z"},
+			nil,
 			"UPDATE some_table SET z = $1 WHERE x = $2 AND z = $3",
 			[]interface{}{"AAA", "CCC", "AAA"},
 		},
+		{
+			tenantEntity{"AAA", "TTT", "BBB"},
+			"some_table",
+			[]string{"b"},
+			[]GenerateOption{WithWhere(&Columns{Cols: []string{"t"}, Vals: []interface{}{"TTT"}})},
+			"UPDATE some_table SET b = $1 WHERE a = $2 AND t = $3",
+			[]interface{}{"BBB", "AAA", "TTT"},
+		},
+		{
+			versionedEntity{"AAA", "BBB", 3},
+			"some_table",
+			nil,
+			nil,
+			"UPDATE some_table SET a = $1, b = $2, v = v + 1 WHERE a = $3 AND v = $4",
+			[]interface{}{"AAA", "BBB", "AAA", 3},
+		},
+		{
+			versionedEntity{"AAA", "BBB", 3},
+			"some_table",
+			[]string{"b", "v"},
+			nil,
+			"UPDATE some_table SET b = $1, v = v + 1 WHERE a = $2 AND v = $3",
+			[]interface{}{"BBB", "AAA", 3},
+		},
+		{
+			stampedEntity{"AAA", "BBB", time.Time{}, time.Time{}},
+			"some_table",
+			nil,
+			nil,
+			"UPDATE some_table SET a = $1, b = $2, u = $3 WHERE a = $4",
+			[]interface{}{"AAA", "BBB", time.Time{}, "AAA"},
+		},
+		{
+			returningEntity{"AAA", "BBB", time.Time{}, 5},
+			"some_table",
+			nil,
+			nil,
+			"UPDATE some_table SET a = $1, b = $2, c = $3 WHERE a = $4 RETURNING c, n",
+			[]interface{}{"AAA", "BBB", time.Time{}, "AAA"},
+		},
 	}
 	gen := &Generator{NewFieldMapper(), true}
 	for _, e := range tests {
-		sql, args := gen.Update(e.Table, e.Entity, e.Columns)
+		sql, args := gen.Update(e.Table, e.Entity, e.Columns, e.Options...)
 		fmt.Println("-->", sql)
 		assert.Equal(t, e.SQL, sql)
 		assert.Equal(t, e.Args, args)
@@ -78,11 +467,12 @@ func TestGeneratorUpdate(t *testing.T) {
 
 func TestGeneratorSelect(t *testing.T) {
 	tests := []struct {
-		Entity interface{}
-		Table  string
-		Keys   *Columns
-		SQL    string
-		Args   []interface{}
+		Entity  interface{}
+		Table   string
+		Keys    *Columns
+		Options []GenerateOption
+		SQL     string
+		Args    []interface{}
 	}{
 		{
 			testEntity{},
@@ -91,6 +481,7 @@ func TestGeneratorSelect(t *testing.T) {
 				Cols: []string{"z"},
 				Vals: []interface{}{"AAA"},
 			},
+			nil,
 			"SELECT e, y, z FROM some_table WHERE z = $1",
 			[]interface{}{"AAA"},
 		},
@@ -101,13 +492,83 @@ func TestGeneratorSelect(t *testing.T) {
 				Cols: []string{"z", "x"},
 				Vals: []interface{}{"AAA", "CCC"},
 			},
+			nil,
 			"SELECT x, y, z FROM some_table WHERE x = $1 AND z = $2",
 			[]interface{}{"CCC", "AAA"},
 		},
+		{
+			tenantEntity{},
+			"some_table",
+			&Columns{
+				Cols: []string{"a"},
+				Vals: []interface{}{"AAA"},
+			},
+			[]GenerateOption{WithWhere(&Columns{Cols: []string{"t"}, Vals: []interface{}{"TTT"}})},
+			"SELECT a, b, t FROM some_table WHERE a = $1 AND t = $2",
+			[]interface{}{"AAA", "TTT"},
+		},
+		{
+			softEntity{},
+			"some_table",
+			&Columns{
+				Cols: []string{"a"},
+				Vals: []interface{}{"AAA"},
+			},
+			[]GenerateOption{WithWhere(&Columns{Cols: []string{"d"}, Vals: []interface{}{nil}})},
+			"SELECT a, b, d FROM some_table WHERE a = $1 AND d IS NULL",
+			[]interface{}{"AAA"},
+		},
 	}
 	gen := &Generator{NewFieldMapper(), true}
 	for _, e := range tests {
-		sql, args := gen.Select(e.Table, e.Entity, e.Keys)
+		sql, args := gen.Select(e.Table, e.Entity, e.Keys, e.Options...)
+		fmt.Println("-->", sql)
+		assert.Equal(t, e.SQL, sql)
+		assert.Equal(t, e.Args, args)
+	}
+}
+
+func TestGeneratorAssign(t *testing.T) {
+	tests := []struct {
+		Table   string
+		Set     *Columns
+		Keys    *Columns
+		Options []GenerateOption
+		SQL     string
+		Args    []interface{}
+	}{
+		{
+			"some_table",
+			&Columns{
+				Cols: []string{"d"},
+				Vals: []interface{}{"DDD"},
+			},
+			&Columns{
+				Cols: []string{"a"},
+				Vals: []interface{}{"AAA"},
+			},
+			nil,
+			"UPDATE some_table SET d = $1 WHERE a = $2",
+			[]interface{}{"DDD", "AAA"},
+		},
+		{
+			"some_table",
+			&Columns{
+				Cols: []string{"d", "c"},
+				Vals: []interface{}{nil, "CCC"},
+			},
+			&Columns{
+				Cols: []string{"a"},
+				Vals: []interface{}{"AAA"},
+			},
+			[]GenerateOption{WithWhere(&Columns{Cols: []string{"t"}, Vals: []interface{}{"TTT"}})},
+			"UPDATE some_table SET c = $1, d = $2 WHERE a = $3 AND t = $4",
+			[]interface{}{"CCC", nil, "AAA", "TTT"},
+		},
+	}
+	gen := &Generator{NewFieldMapper(), true}
+	for _, e := range tests {
+		sql, args := gen.Assign(e.Table, e.Set, e.Keys, e.Options...)
 		fmt.Println("-->", sql)
 		assert.Equal(t, e.SQL, sql)
 		assert.Equal(t, e.Args, args)
@@ -116,11 +577,12 @@ func TestGeneratorSelect(t *testing.T) {
 
 func TestGeneratorDelete(t *testing.T) {
 	tests := []struct {
-		Entity interface{}
-		Table  string
-		Keys   *Columns
-		SQL    string
-		Args   []interface{}
+		Entity  interface{}
+		Table   string
+		Keys    *Columns
+		Options []GenerateOption
+		SQL     string
+		Args    []interface{}
 	}{
 		{
 			testEntity{},
@@ -129,6 +591,7 @@ func TestGeneratorDelete(t *testing.T) {
 				Cols: []string{"z"},
 				Vals: []interface{}{"AAA"},
 			},
+			nil,
 			"DELETE FROM some_table WHERE z = $1",
 			[]interface{}{"AAA"},
 		},
@@ -139,13 +602,97 @@ func TestGeneratorDelete(t *testing.T) {
 				Cols: []string{"z", "x"},
 				Vals: []interface{}{"AAA", "CCC"},
 			},
+			nil,
 			"DELETE FROM some_table WHERE x = $1 AND z = $2",
 			[]interface{}{"CCC", "AAA"},
 		},
+		{
+			tenantEntity{},
+			"some_table",
+			&Columns{
+				Cols: []string{"a"},
+				Vals: []interface{}{"AAA"},
+			},
+			[]GenerateOption{WithWhere(&Columns{Cols: []string{"t"}, Vals: []interface{}{"TTT"}})},
+			"DELETE FROM some_table WHERE a = $1 AND t = $2",
+			[]interface{}{"AAA", "TTT"},
+		},
+	}
+	gen := &Generator{NewFieldMapper(), true}
+	for _, e := range tests {
+		sql, args := gen.Delete(e.Table, e.Keys, e.Options...)
+		fmt.Println("-->", sql)
+		assert.Equal(t, e.SQL, sql)
+		assert.Equal(t, e.Args, args)
+	}
+}
+
+func TestGeneratorExists(t *testing.T) {
+	tests := []struct {
+		Table   string
+		Keys    *Columns
+		Options []GenerateOption
+		SQL     string
+		Args    []interface{}
+	}{
+		{
+			"some_table",
+			&Columns{
+				Cols: []string{"z"},
+				Vals: []interface{}{"AAA"},
+			},
+			nil,
+			"SELECT EXISTS (SELECT 1 FROM some_table WHERE z = $1)",
+			[]interface{}{"AAA"},
+		},
+		{
+			"some_table",
+			&Columns{
+				Cols: []string{"a"},
+				Vals: []interface{}{"AAA"},
+			},
+			[]GenerateOption{WithWhere(&Columns{Cols: []string{"t"}, Vals: []interface{}{"TTT"}})},
+			"SELECT EXISTS (SELECT 1 FROM some_table WHERE a = $1 AND t = $2)",
+			[]interface{}{"AAA", "TTT"},
+		},
+	}
+	gen := &Generator{NewFieldMapper(), true}
+	for _, e := range tests {
+		sql, args := gen.Exists(e.Table, e.Keys, e.Options...)
+		fmt.Println("-->", sql)
+		assert.Equal(t, e.SQL, sql)
+		assert.Equal(t, e.Args, args)
+	}
+}
+
+func TestGeneratorWhere(t *testing.T) {
+	tests := []struct {
+		Options []GenerateOption
+		SQL     string
+		Args    []interface{}
+	}{
+		{
+			nil,
+			"",
+			nil,
+		},
+		{
+			[]GenerateOption{WithWhere(nil)},
+			"",
+			nil,
+		},
+		{
+			[]GenerateOption{
+				WithWhere(&Columns{Cols: []string{"t"}, Vals: []interface{}{"TTT"}}),
+				WithWhere(&Columns{Cols: []string{"d"}, Vals: []interface{}{nil}}),
+			},
+			"WHERE t = $1 AND d IS NULL",
+			[]interface{}{"TTT"},
+		},
 	}
 	gen := &Generator{NewFieldMapper(), true}
 	for _, e := range tests {
-		sql, args := gen.Delete(e.Table, e.Keys)
+		sql, args := gen.Where(e.Options...)
 		fmt.Println("-->", sql)
 		assert.Equal(t, e.SQL, sql)
 		assert.Equal(t, e.Args, args)
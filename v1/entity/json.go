@@ -0,0 +1,71 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/bww/go-dbx/v1"
+)
+
+// IsJSON determines if the field of a type identified by the provided
+// traversal is stored as a JSON document. Such fields are tagged with the
+// json option, for example:
+//
+//	Attrs map[string]interface{} `db:"attrs,json"`
+func (m *FieldMapper) IsJSON(typ reflect.Type, index []int) bool {
+	return m.fieldHasOption(typ, index, optionJSON)
+}
+
+// jsonValue marshals a value as JSON when it is provided to the driver. The
+// document is provided as a string, which is accepted by both json and jsonb
+// columns. A nil pointer, map, slice or interface is stored as NULL.
+type jsonValue struct {
+	v reflect.Value
+}
+
+func (j jsonValue) Value() (driver.Value, error) {
+	if !j.v.IsValid() || isNil(j.v) {
+		return nil, nil
+	}
+	data, err := json.Marshal(j.v.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// JSONScanner unmarshals a JSON document read from the database into the
+// field it wraps. NULL produces the zero value of the field.
+type JSONScanner struct {
+	dst reflect.Value
+}
+
+// NewJSONScanner creates a scanner for the provided field, which must be
+// settable.
+func NewJSONScanner(dst reflect.Value) JSONScanner {
+	return JSONScanner{dst: dst}
+}
+
+func (s JSONScanner) Scan(src interface{}) error {
+	var data []byte
+	switch c := src.(type) {
+	case nil:
+		s.dst.Set(reflect.Zero(s.dst.Type()))
+		return nil
+	case []byte:
+		data = c
+	case string:
+		data = []byte(c)
+	default:
+		return fmt.Errorf("%w: cannot unmarshal %T as JSON", dbx.ErrInvalidField, src)
+	}
+	v := reflect.New(s.dst.Type())
+	err := json.Unmarshal(data, v.Interface())
+	if err != nil {
+		return err
+	}
+	s.dst.Set(v.Elem())
+	return nil
+}
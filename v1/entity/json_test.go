@@ -0,0 +1,55 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type jsonEntity struct {
+	A string            `db:"a,pk"`
+	B map[string]int    `db:"b,json"`
+	C *struct{ X int }  `db:"c,json"`
+	D []string          `db:"d,json,omitempty"`
+	E map[string]string `db:"e"`
+}
+
+func TestJSON(t *testing.T) {
+	m := NewFieldMapper()
+	typ := reflect.TypeOf(&jsonEntity{})
+
+	e := &jsonEntity{A: "A", B: map[string]int{"x": 1}}
+	_, cols := m.Columns(e)
+	vals := make([]interface{}, len(cols.Vals))
+	for i, v := range cols.Vals {
+		if c, ok := v.(driver.Valuer); ok {
+			x, err := c.Value()
+			assert.Nil(t, err, fmt.Sprint(err))
+			vals[i] = x
+		} else {
+			vals[i] = v
+		}
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, cols.Cols)
+	assert.Equal(t, []interface{}{"A", `{"x":1}`, nil, nil, map[string]string(nil)}, vals)
+
+	for i, n := range []string{"a", "b", "c", "d", "e"} {
+		f := m.TypeMap(typ.Elem()).GetByPath(n)
+		assert.Equal(t, i > 0 && i < 4, m.IsJSON(typ, f.Index), n)
+	}
+
+	var d jsonEntity
+	v := reflect.ValueOf(&d).Elem()
+	assert.Nil(t, NewJSONScanner(v.FieldByName("B")).Scan([]byte(`{"y":2}`)))
+	assert.Nil(t, NewJSONScanner(v.FieldByName("C")).Scan(`{"X":3}`))
+	assert.Nil(t, NewJSONScanner(v.FieldByName("D")).Scan(nil))
+	assert.Equal(t, map[string]int{"y": 2}, d.B)
+	if assert.NotNil(t, d.C) {
+		assert.Equal(t, 3, d.C.X)
+	}
+	assert.Nil(t, d.D)
+	assert.NotNil(t, NewJSONScanner(v.FieldByName("B")).Scan(10))
+}
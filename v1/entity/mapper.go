@@ -3,10 +3,13 @@ package entity
 import (
 	"reflect"
 	"runtime"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/bww/go-dbx/v1"
 	"github.com/jmoiron/sqlx/reflectx"
+	"github.com/lib/pq"
 )
 
 const Tag = "db"
@@ -14,6 +17,16 @@ const (
 	optionPrimaryKey = "pk"
 	optionOmitPQL    = "omitpql" // don't expand in PQL expressions
 	optionOmitEmpty  = "omitempty"
+	optionTenant     = "tenant"    // identifies the tenant which owns an entity
+	optionVersion    = "version"   // a row version used for optimistic locking
+	optionDeleted    = "deleted"   // the time at which an entity was soft deleted
+	optionCreated    = "created"   // the time at which an entity was inserted
+	optionUpdated    = "updated"   // the time at which an entity was last written
+	optionReturning  = "returning" // read back from the database after a write
+	optionGenerated  = "generated" // generated by the database; implies returning
+	optionJSON       = "json"      // stored as a JSON document
+	optionArray      = "array"     // stored as a Postgres array
+	optionEncrypt    = "encrypt"   // encrypted by the mapper's cipher
 )
 
 var (
@@ -34,6 +47,13 @@ type Synthetic interface {
 
 var typeOfSynthetic = reflect.TypeOf((*Synthetic)(nil)).Elem()
 
+// Newer is implemented by entities that know whether they have been stored
+// or not. When an entity implements Newer, it determines whether the entity
+// is inserted or updated when it is stored, rather than the entity's keys.
+type Newer interface {
+	IsNew() bool
+}
+
 type FieldFilter func(*reflectx.FieldInfo) bool
 
 func ExcludeFromPQL(f *reflectx.FieldInfo) bool {
@@ -50,68 +70,273 @@ func DefaultFieldMapper() *FieldMapper {
 
 type FieldMapper struct {
 	*reflectx.Mapper
+	checks     sync.Map // reflect.Type → []fieldCheck; see Validate
+	converters map[reflect.Type]Converter
+	cipher     Cipher
+	naming     NamingStrategy
+	names      sync.Map // reflect.Type → *reflectx.StructMap; see TypeMap
+	namesLock  sync.Mutex
 }
 
-func NewFieldMapper() *FieldMapper {
-	return &FieldMapper{
+func NewFieldMapper(opts ...MapperOption) *FieldMapper {
+	m := &FieldMapper{
 		Mapper: reflectx.NewMapperFunc(Tag, ignoreField),
 	}
+	for _, o := range opts {
+		o(m)
+	}
+	return m
 }
 
 func (m *FieldMapper) KeysForType(typ reflect.Type) []string {
-	var cols []string
+	return m.columnsWithOption(typ, optionPrimaryKey)
+}
+
+func (m *FieldMapper) Keys(entity interface{}) (*Values, error) {
+	return m.valuesWithOption(entity, optionPrimaryKey)
+}
+
+// TenantForType produces the columns of a type which identify the tenant
+// that owns an entity. Most types declare either zero or one such column.
+func (m *FieldMapper) TenantForType(typ reflect.Type) []string {
+	return m.columnsWithOption(typ, optionTenant)
+}
+
+// Tenant produces the settable values of the tenant columns of an entity.
+func (m *FieldMapper) Tenant(entity interface{}) (*Values, error) {
+	return m.valuesWithOption(entity, optionTenant)
+}
+
+// VersionForType produces the version columns declared by an entity type.
+// An entity should declare at most one; it must be an integer.
+func (m *FieldMapper) VersionForType(typ reflect.Type) []string {
+	return m.columnsWithOption(typ, optionVersion)
+}
+
+// Version produces the version fields of an entity.
+func (m *FieldMapper) Version(entity interface{}) (*Values, error) {
+	return m.valuesWithOption(entity, optionVersion)
+}
+
+// DeletedForType produces the columns of a type which record when an entity
+// was soft deleted. Such a column is NULL for entities which are not deleted.
+func (m *FieldMapper) DeletedForType(typ reflect.Type) []string {
+	return m.columnsWithOption(typ, optionDeleted)
+}
+
+// Deleted produces the soft delete fields of an entity. These fields must be
+// able to represent NULL, which identifies an entity that is not deleted, so
+// they are pointers or types like sql.NullTime; ErrInvalidField is returned
+// for fields which are not.
+func (m *FieldMapper) Deleted(entity interface{}) (*Values, error) {
+	vals, err := m.valuesWithOption(entity, optionDeleted)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range vals.Vals {
+		if !e.IsValid() || !nullable(e.Type()) {
+			return nil, dbx.ErrInvalidField
+		}
+	}
+	return vals, nil
+}
+
+// CreatedForType produces the columns of a type which record when an entity
+// was created. These columns are written when an entity is inserted and are
+// not modified thereafter.
+func (m *FieldMapper) CreatedForType(typ reflect.Type) []string {
+	return m.columnsWithOption(typ, optionCreated)
+}
+
+// Created produces the creation time fields of an entity.
+func (m *FieldMapper) Created(entity interface{}) (*Values, error) {
+	return m.valuesWithOption(entity, optionCreated)
+}
+
+// UpdatedForType produces the columns of a type which record when an entity
+// was last written.
+func (m *FieldMapper) UpdatedForType(typ reflect.Type) []string {
+	return m.columnsWithOption(typ, optionUpdated)
+}
+
+// Updated produces the update time fields of an entity.
+func (m *FieldMapper) Updated(entity interface{}) (*Values, error) {
+	return m.valuesWithOption(entity, optionUpdated)
+}
+
+// ReturningForType produces the columns of a type which are read back from
+// the database after an entity is written, because the database may have
+// assigned them a value. Generated columns are always included.
+func (m *FieldMapper) ReturningForType(typ reflect.Type) []string {
+	return m.columnsWithOption(typ, optionReturning, optionGenerated)
+}
+
+// GeneratedForType produces the columns of a type whose values are generated
+// by the database. Such columns are never written, except for generated
+// primary keys, which are inserted if the entity provides a value for them.
+func (m *FieldMapper) GeneratedForType(typ reflect.Type) []string {
+	return m.columnsWithOption(typ, optionGenerated)
+}
+
+// KeyColumns pairs the primary key columns of a type with the values of an
+// identifier. For a type with a single primary key, the identifier is the
+// value of that key. For a type with a composite key the identifier may be:
+//
+//   - a slice or array of values, one for each key in the order the key
+//     fields are declared,
+//   - a map of key column names to values, or
+//   - a struct, or pointer to a struct, which maps a field to every key
+//     column; for example, an instance of the type itself.
+func (m *FieldMapper) KeyColumns(typ reflect.Type, id interface{}) (*Columns, error) {
+	keys := m.KeysForType(typ)
+	switch len(keys) {
+	case 0:
+		return nil, dbx.ErrInvalidKeyCount
+	case 1:
+		return &Columns{Cols: keys, Vals: []interface{}{id}}, nil
+	}
+
+	vals := make([]interface{}, len(keys))
+	v := reflect.Indirect(reflect.ValueOf(id))
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if v.Len() != len(keys) {
+			return nil, dbx.ErrInvalidKeyCount
+		}
+		for i := range keys {
+			vals[i] = v.Index(i).Interface()
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, dbx.ErrInvalidField
+		}
+		for i, k := range keys {
+			e := v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key()))
+			if !e.IsValid() {
+				return nil, dbx.ErrMissingField
+			}
+			vals[i] = e.Interface()
+		}
+	case reflect.Struct:
+		x := m.TypeMap(v.Type())
+		for i, k := range keys {
+			f, ok := x.Names[k]
+			if !ok || !isExplicitMapping(f) {
+				return nil, dbx.ErrMissingField
+			}
+			e, ok := FieldByIndexesRO(v, f.Index)
+			if !ok || !e.CanInterface() {
+				return nil, dbx.ErrMissingField
+			}
+			vals[i] = e.Interface()
+		}
+	default:
+		return nil, dbx.ErrInvalidField
+	}
+
+	return &Columns{Cols: keys, Vals: vals}, nil
+}
+
+// fieldsWithOption produces the explicitly mapped fields of a type which
+// have the specified option, in the order they are declared.
+func (m *FieldMapper) fieldsWithOption(typ reflect.Type, opts ...string) []*reflectx.FieldInfo {
+	var fields []*reflectx.FieldInfo
 	x := m.TypeMap(typ)
-	for k, f := range x.Names {
+	for _, f := range x.Names {
 		if isExplicitMapping(f) {
 			if f.Options != nil {
-				if _, ok := f.Options[optionPrimaryKey]; ok {
-					cols = append(cols, k)
+				for _, opt := range opts {
+					if _, ok := f.Options[opt]; ok {
+						fields = append(fields, f)
+						break
+					}
 				}
 			}
 		}
 	}
+	sort.Slice(fields, func(i, j int) bool {
+		return lessIndex(fields[i].Index, fields[j].Index)
+	})
+	return fields
+}
+
+// fieldHasOption determines if the field of a type identified by the provided
+// traversal declares an option.
+func (m *FieldMapper) fieldHasOption(typ reflect.Type, index []int, opt string) bool {
+	f := m.TypeMap(reflectx.Deref(typ)).GetByTraversal(index)
+	if f == nil || f.Options == nil {
+		return false
+	}
+	_, ok := f.Options[opt]
+	return ok
+}
+
+// fieldsWithTag produces the mapped fields of a type which declare the named
+// struct tag, in declaration order.
+func (m *FieldMapper) fieldsWithTag(typ reflect.Type, tag string) []*reflectx.FieldInfo {
+	var fields []*reflectx.FieldInfo
+	x := m.TypeMap(typ)
+	for _, f := range x.Names {
+		if isExplicitMapping(f) {
+			if _, ok := f.Field.Tag.Lookup(tag); ok {
+				fields = append(fields, f)
+			}
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return lessIndex(fields[i].Index, fields[j].Index)
+	})
+	return fields
+}
+
+func (m *FieldMapper) columnsWithOption(typ reflect.Type, opts ...string) []string {
+	var cols []string
+	for _, f := range m.fieldsWithOption(typ, opts...) {
+		cols = append(cols, f.Path)
+	}
 	return cols
 }
 
-func (m *FieldMapper) Keys(entity interface{}) (*Values, error) {
-	var kcols []string
-	var kvals []reflect.Value
+func (m *FieldMapper) valuesWithOption(entity interface{}, opt string) (*Values, error) {
+	var vcols []string
+	var vvals []reflect.Value
 
 	e := reflect.ValueOf(entity)
-	x := m.TypeMap(e.Type())
-
 	if e.Kind() != reflect.Ptr {
 		return nil, dbx.ErrNotAPointer
 	}
 
-	for k, f := range x.Names {
-		if isExplicitMapping(f) {
-			if f.Options != nil {
-				if _, ok := f.Options[optionPrimaryKey]; ok {
-					kcols = append(kcols, k)
-					kvals = append(kvals, FieldByIndexes(e, f.Index))
-				}
-			}
-		}
+	for _, f := range m.fieldsWithOption(e.Type(), opt) {
+		vcols = append(vcols, f.Path)
+		vvals = append(vvals, FieldByIndexes(e, f.Index))
 	}
 
-	return &Values{kcols, kvals}, nil
+	return &Values{vcols, vvals}, nil
 }
 
+// ColumnsForType produces the mapped columns of a type which satisfy every
+// filter, in the order their fields are declared.
 func (m *FieldMapper) ColumnsForType(typ reflect.Type, filters ...FieldFilter) []string {
-	var cols []string
+	var fields []*reflectx.FieldInfo
 	x := m.TypeMap(typ)
 outer:
-	for k, f := range x.Names {
+	for _, f := range x.Names {
 		for _, filter := range filters {
 			if !filter(f) {
 				continue outer
 			}
 		}
 		if isExplicitMapping(f) {
-			cols = append(cols, k)
+			fields = append(fields, f)
 		}
 	}
+	sort.Slice(fields, func(i, j int) bool {
+		return lessIndex(fields[i].Index, fields[j].Index)
+	})
+	cols := make([]string, len(fields))
+	for i, f := range fields {
+		cols[i] = f.Path
+	}
 	return cols
 }
 
@@ -122,7 +347,18 @@ func (m *FieldMapper) Columns(entity interface{}) (*Columns, *Columns) {
 	e := reflect.ValueOf(entity)
 	x := m.TypeMap(e.Type())
 
-	for k, f := range x.Names {
+	// columns are produced in the order their fields are declared so that
+	// the statements generated for a type are always the same
+	fields := make([]*reflectx.FieldInfo, 0, len(x.Names))
+	for _, f := range x.Names {
+		fields = append(fields, f)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return lessIndex(fields[i].Index, fields[j].Index)
+	})
+
+	for _, f := range fields {
+		k := f.Path
 		if isExplicitMapping(f) {
 			var x interface{}
 
@@ -137,10 +373,27 @@ func (m *FieldMapper) Columns(entity interface{}) (*Columns, *Columns) {
 				x = f.Zero.Interface()
 			}
 
+			empty, json, array, encrypt := false, false, false, false
+			var mode string
+			if f.Options != nil {
+				_, omit := f.Options[optionOmitEmpty]
+				empty = omit && isEmptyValue(x, v)
+				_, json = f.Options[optionJSON]
+				_, array = f.Options[optionArray]
+				mode, encrypt = f.Options[optionEncrypt]
+			}
+			if encrypt {
+				x = encryptedValue{cipher: m.cipher, v: v, deterministic: mode == encryptDeterministic}
+			} else if json {
+				x = jsonValue{v}
+			} else if array {
+				x = pq.Array(x)
+			} else if v.IsValid() {
+				x = m.convertTo(v, x)
+			}
+
 			vcols = append(vcols, k)
-			if f.Options == nil {
-				vvals = append(vvals, x)
-			} else if _, ok := f.Options[optionOmitEmpty]; ok && isEmptyValue(x, v) {
+			if empty {
 				vvals = append(vvals, nil)
 			} else {
 				vvals = append(vvals, x)
@@ -167,6 +420,43 @@ func (m *FieldMapper) Columns(entity interface{}) (*Columns, *Columns) {
 	return rkcols, rvcols
 }
 
+// Tabulate produces the columns for a set of entities, sorted by name, and
+// a row of values for each entity that is aligned with those columns. The
+// columns are the union of the columns produced by every entity; if an
+// entity does not produce a column, its value in that entity's row is nil.
+func (m *FieldMapper) Tabulate(entities []interface{}) ([]string, [][]interface{}) {
+	var cols []string
+	index := make(map[string]int)
+	vals := make([]*Columns, len(entities))
+
+	for i, e := range entities {
+		_, c := m.Columns(e)
+		for _, n := range c.Cols {
+			if _, ok := index[n]; !ok {
+				index[n] = -1
+				cols = append(cols, n)
+			}
+		}
+		vals[i] = c
+	}
+
+	sort.Strings(cols)
+	for i, n := range cols {
+		index[n] = i
+	}
+
+	rows := make([][]interface{}, len(entities))
+	for i, c := range vals {
+		row := make([]interface{}, len(cols))
+		for j, n := range c.Cols {
+			row[index[n]] = c.Vals[j]
+		}
+		rows[i] = row
+	}
+
+	return cols, rows
+}
+
 // TraversalsByName returns a slice of int slices which represent the struct
 // traversals for each mapped name and a slice of bools which indicates whether
 // each such traversal is omit-empty.  Panics if t is not a struct or Indirectable
@@ -267,6 +557,19 @@ func ignoreField(n string) string {
 	return ignoreName
 }
 
+// isExplicitMapping determines if a field is mapped to a column. A field is
+// not mapped if it, or a struct it is nested in, is ignored.
 func isExplicitMapping(f *reflectx.FieldInfo) bool {
-	return f.Name != ignoreName
+	return f.Name != ignoreName && !strings.Contains(f.Path, ignoreName)
+}
+
+// lessIndex orders field traversals by the position of the fields they
+// identify in the declaration of a struct.
+func lessIndex(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
 }
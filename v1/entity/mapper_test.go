@@ -1,9 +1,14 @@
 package entity
 
 import (
+	"database/sql"
+	"fmt"
+	"reflect"
 	"sort"
 	"testing"
+	"time"
 
+	"github.com/bww/go-dbx/v1"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -43,3 +48,150 @@ func TestFieldMapper(t *testing.T) {
 		assert.Equal(t, e.Values, c.Vals)
 	}
 }
+
+func TestFieldMapperTenant(t *testing.T) {
+	m := NewFieldMapper()
+	assert.Equal(t, []string{"t"}, m.TenantForType(reflect.TypeOf(tenantEntity{})))
+	assert.Equal(t, []string(nil), m.TenantForType(reflect.TypeOf(testEntity{})))
+
+	e := &tenantEntity{A: "AAA"}
+	v, err := m.Tenant(e)
+	if assert.Nil(t, err, fmt.Sprint(err)) && assert.Len(t, v.Vals, 1) {
+		assert.Equal(t, []string{"t"}, v.Cols)
+		v.Vals[0].Set(reflect.ValueOf("TTT"))
+		assert.Equal(t, "TTT", e.T)
+	}
+
+	_, err = m.Tenant(tenantEntity{})
+	assert.Equal(t, dbx.ErrNotAPointer, err)
+}
+
+func TestFieldMapperDeleted(t *testing.T) {
+	m := NewFieldMapper()
+
+	v, err := m.Deleted(&struct {
+		A *time.Time   `db:"a,deleted"`
+		B sql.NullTime `db:"b,deleted"`
+	}{})
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, []string{"a", "b"}, v.Cols)
+	}
+
+	_, err = m.Deleted(&struct {
+		A time.Time `db:"a,deleted"`
+	}{})
+	assert.Equal(t, dbx.ErrInvalidField, err) // a zero time is not NULL
+}
+
+func TestFieldMapperTabulate(t *testing.T) {
+	m := NewFieldMapper()
+	cols, rows := m.Tabulate([]interface{}{
+		testEntity{embedEntity{"BBB"}, "AAA", 999, 111},
+		&testEntity{embedEntity{"DDD"}, "CCC", 999, 0},
+		syntheticEntity{"EEE"},
+	})
+	assert.Equal(t, []string{"a", "e", "syn_1", "y", "z"}, cols)
+	assert.Equal(t, [][]interface{}{
+		{nil, 111, nil, "BBB", "AAA"},
+		{nil, nil, nil, "DDD", "CCC"},
+		{"EEE", nil, 123, nil, nil},
+	}, rows)
+}
+
+func TestFieldMapperKeyColumns(t *testing.T) {
+	m := NewFieldMapper()
+	single, multi := reflect.TypeOf(testEntity{}), reflect.TypeOf(&multiPKEntity{})
+
+	assert.Equal(t, []string{"z", "x"}, m.KeysForType(multi))
+
+	tests := []struct {
+		Type   reflect.Type
+		ID     interface{}
+		Expect *Columns
+		Error  error
+	}{
+		{
+			single,
+			"AAA",
+			&Columns{Cols: []string{"z"}, Vals: []interface{}{"AAA"}},
+			nil,
+		},
+		{
+			single,
+			[]byte("AAA"), // a single key is never interpreted
+			&Columns{Cols: []string{"z"}, Vals: []interface{}{[]byte("AAA")}},
+			nil,
+		},
+		{
+			multi,
+			[]string{"AAA", "CCC"},
+			&Columns{Cols: []string{"z", "x"}, Vals: []interface{}{"AAA", "CCC"}},
+			nil,
+		},
+		{
+			multi,
+			[2]interface{}{"AAA", "CCC"},
+			&Columns{Cols: []string{"z", "x"}, Vals: []interface{}{"AAA", "CCC"}},
+			nil,
+		},
+		{
+			multi,
+			map[string]interface{}{"x": "CCC", "z": "AAA"},
+			&Columns{Cols: []string{"z", "x"}, Vals: []interface{}{"AAA", "CCC"}},
+			nil,
+		},
+		{
+			multi,
+			&multiPKEntity{A: "AAA", C: "CCC"},
+			&Columns{Cols: []string{"z", "x"}, Vals: []interface{}{"AAA", "CCC"}},
+			nil,
+		},
+		{
+			multi,
+			struct {
+				Z string `db:"z"`
+				X string `db:"x"`
+			}{"AAA", "CCC"},
+			&Columns{Cols: []string{"z", "x"}, Vals: []interface{}{"AAA", "CCC"}},
+			nil,
+		},
+		{
+			multi,
+			[]string{"AAA"},
+			nil,
+			dbx.ErrInvalidKeyCount,
+		},
+		{
+			multi,
+			map[string]interface{}{"z": "AAA"},
+			nil,
+			dbx.ErrMissingField,
+		},
+		{
+			multi,
+			testEntity{},
+			nil,
+			dbx.ErrMissingField,
+		},
+		{
+			multi,
+			"AAA",
+			nil,
+			dbx.ErrInvalidField,
+		},
+		{
+			reflect.TypeOf(embedEntity{}),
+			"AAA",
+			nil,
+			dbx.ErrInvalidKeyCount,
+		},
+	}
+	for _, e := range tests {
+		c, err := m.KeyColumns(e.Type, e.ID)
+		if e.Error != nil {
+			assert.Equal(t, e.Error, err)
+		} else if assert.Nil(t, err, fmt.Sprint(err)) {
+			assert.Equal(t, e.Expect, c)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package entity
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// NamingStrategy produces the column name of a field which is not tagged
+// with an explicit name. If it produces the empty string, the field is not
+// mapped. By default fields must be tagged to be mapped.
+type NamingStrategy func(reflect.StructField) string
+
+// WithNaming sets the strategy used to name the columns of fields which do
+// not have a db tag. Fields which are tagged are always mapped by their tag
+// and fields tagged with "-" are never mapped. For example:
+//
+//	fm := entity.NewFieldMapper(entity.WithNaming(entity.SnakeCase))
+func WithNaming(s NamingStrategy) MapperOption {
+	return func(m *FieldMapper) {
+		m.naming = s
+	}
+}
+
+// SnakeCase names a column by converting the name of its field to snake
+// case. Acronyms are treated as a single word, for example, a field named
+// UserID is mapped to the column user_id.
+func SnakeCase(f reflect.StructField) string {
+	r := []rune(f.Name)
+	b := &strings.Builder{}
+	for i, c := range r {
+		if i > 0 && unicode.IsUpper(c) {
+			p := r[i-1]
+			if unicode.IsLower(p) || unicode.IsDigit(p) || (unicode.IsUpper(p) && i+1 < len(r) && unicode.IsLower(r[i+1])) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(c))
+	}
+	return b.String()
+}
+
+// LowerCase names a column by converting the name of its field to lower
+// case, for example, a field named UserID is mapped to the column userid.
+func LowerCase(f reflect.StructField) string {
+	return strings.ToLower(f.Name)
+}
+
+// JSONName names a column with the name in its field's json tag. Fields
+// which have no json tag, or whose json tag does not provide a name, are
+// not mapped.
+func JSONName(f reflect.StructField) string {
+	n, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if n == "-" {
+		return ""
+	}
+	return n
+}
+
+// TypeMap produces the mapping for a type. When a naming strategy is set
+// the names of fields which are not tagged are produced by it.
+func (m *FieldMapper) TypeMap(t reflect.Type) *reflectx.StructMap {
+	if m.naming == nil {
+		return m.Mapper.TypeMap(t)
+	}
+	if v, ok := m.names.Load(t); ok {
+		return v.(*reflectx.StructMap)
+	}
+	m.namesLock.Lock()
+	defer m.namesLock.Unlock()
+	if v, ok := m.names.Load(t); ok {
+		return v.(*reflectx.StructMap)
+	}
+	x := copyStructMap(m.Mapper.TypeMap(t))
+	m.rename(x)
+	m.names.Store(t, x)
+	return x
+}
+
+// copyStructMap produces a deep copy of a mapping, which may be modified
+// without affecting the mapping cached by reflectx.
+func copyStructMap(x *reflectx.StructMap) *reflectx.StructMap {
+	copies := make(map[*reflectx.FieldInfo]*reflectx.FieldInfo)
+	var clone func(f, parent *reflectx.FieldInfo) *reflectx.FieldInfo
+	clone = func(f, parent *reflectx.FieldInfo) *reflectx.FieldInfo {
+		c := new(reflectx.FieldInfo)
+		*c = *f
+		c.Parent = parent
+		if f.Children != nil {
+			c.Children = make([]*reflectx.FieldInfo, len(f.Children))
+			for i, e := range f.Children {
+				if e != nil {
+					c.Children[i] = clone(e, c)
+				}
+			}
+		}
+		copies[f] = c
+		return c
+	}
+
+	res := &reflectx.StructMap{
+		Tree:  clone(x.Tree, nil),
+		Index: make([]*reflectx.FieldInfo, len(x.Index)),
+	}
+	for i, f := range x.Index {
+		res.Index[i] = copies[f]
+	}
+	return res
+}
+
+// rename applies the naming strategy to the untagged fields of a mapping
+// and rebuilds the paths of its fields in the same way reflectx does.
+func (m *FieldMapper) rename(x *reflectx.StructMap) {
+	prefixes := map[*reflectx.FieldInfo]string{x.Tree: ""}
+	for _, f := range x.Index { // parents precede their children
+		if n, _, _ := strings.Cut(f.Field.Tag.Get(Tag), ","); n == "" {
+			if n := m.naming(f.Field); n != "" {
+				f.Name = n
+			} else {
+				f.Name = ignoreName
+			}
+		}
+		if p := prefixes[f.Parent]; p != "" {
+			f.Path = p + "." + f.Name
+		} else {
+			f.Path = f.Name
+		}
+		if f.Embedded && f.Field.Tag.Get(Tag) == "" {
+			prefixes[f] = prefixes[f.Parent]
+		} else {
+			prefixes[f] = f.Path
+		}
+	}
+
+	x.Paths = make(map[string]*reflectx.FieldInfo)
+	x.Names = make(map[string]*reflectx.FieldInfo)
+	for _, f := range x.Index {
+		e, ok := x.Paths[f.Path]
+		if !ok || e.Embedded {
+			x.Paths[f.Path] = f
+			if f.Name != "" && !f.Embedded {
+				x.Names[f.Path] = f
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type namedAddress struct {
+	StreetName string
+	ZIPCode    string `db:"zip"`
+}
+
+type namedEntity struct {
+	Tracked
+	ID         string       `db:"id,pk" json:"id"`
+	UserName   string       `json:"user_name"`
+	HTTPPort   int          `json:"-"`
+	Plain      string       `db:",omitempty"`
+	Skipped    string       `db:"-" json:"skipped"`
+	Address    namedAddress `json:"address"`
+	CreatedAt  time.Time
+	unexported string
+}
+
+func TestNamingStrategies(t *testing.T) {
+	typ := reflect.TypeOf(namedEntity{})
+	tests := []struct {
+		Mapper *FieldMapper
+		Expect []string
+	}{
+		{
+			NewFieldMapper(),
+			[]string{"id"},
+		},
+		{
+			NewFieldMapper(WithNaming(SnakeCase)),
+			[]string{"id", "user_name", "http_port", "plain", "address", "address.street_name", "address.zip", "created_at"},
+		},
+		{
+			NewFieldMapper(WithNaming(LowerCase)),
+			[]string{"id", "username", "httpport", "plain", "address", "address.streetname", "address.zip", "createdat"},
+		},
+		{
+			NewFieldMapper(WithNaming(JSONName)),
+			[]string{"id", "user_name", "address", "address.zip"},
+		},
+	}
+	for _, e := range tests {
+		cols := e.Mapper.ColumnsForType(typ)
+		assert.Equal(t, e.Expect, cols)
+
+		_, vals := e.Mapper.Columns(&namedEntity{ID: "A", UserName: "B"})
+		assert.Equal(t, e.Expect, vals.Cols)
+	}
+
+	m := NewFieldMapper(WithNaming(SnakeCase))
+	f, _ := m.TraversalsByName(typ, []string{"user_name", "address.street_name", "unexported"})
+	assert.Equal(t, [][]int{{2}, {6, 0}, {}}, f)
+	assert.Equal(t, []string{"id"}, m.KeysForType(typ))
+
+	// the mapping cached by the underlying mapper is not renamed
+	assert.Contains(t, m.TypeMap(typ).Names, "user_name")
+	assert.NotContains(t, m.Mapper.TypeMap(typ).Names, "user_name")
+	assert.NotEqual(t, "user_name", m.Mapper.TypeMap(typ).Index[2].Name)
+}
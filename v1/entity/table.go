@@ -0,0 +1,36 @@
+package entity
+
+import (
+	"reflect"
+)
+
+// Tabler is implemented by entities which declare the table they are
+// stored in. The method is invoked on the zero value of the type, so it
+// must not depend on the state of the entity. For example:
+//
+//	func (User) Table() string { return "users" }
+//
+// Persister methods resolve an empty table name to the declared table.
+type Tabler interface {
+	Table() string
+}
+
+var typeOfTabler = reflect.TypeOf((*Tabler)(nil)).Elem()
+
+// TableForType produces the table declared by a type, which must implement
+// Tabler either directly or via a pointer. Pointer types are dereferenced.
+func TableForType(typ reflect.Type) (string, bool) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	var v reflect.Value
+	if typ.Implements(typeOfTabler) {
+		v = reflect.Zero(typ)
+	} else if reflect.PointerTo(typ).Implements(typeOfTabler) {
+		v = reflect.New(typ)
+	} else {
+		return "", false
+	}
+	t := v.Interface().(Tabler).Table()
+	return t, t != ""
+}
@@ -0,0 +1,35 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type valueTabler struct{}
+
+func (valueTabler) Table() string { return "value_table" }
+
+type pointerTabler struct{}
+
+func (*pointerTabler) Table() string { return "pointer_table" }
+
+func TestTableForType(t *testing.T) {
+	tests := []struct {
+		Type   reflect.Type
+		Expect string
+		OK     bool
+	}{
+		{reflect.TypeOf(valueTabler{}), "value_table", true},
+		{reflect.TypeOf(&valueTabler{}), "value_table", true},
+		{reflect.TypeOf(pointerTabler{}), "pointer_table", true},
+		{reflect.TypeOf(&pointerTabler{}), "pointer_table", true},
+		{reflect.TypeOf(testEntity{}), "", false},
+	}
+	for _, e := range tests {
+		n, ok := TableForType(e.Type)
+		assert.Equal(t, e.OK, ok, e.Type)
+		assert.Equal(t, e.Expect, n, e.Type)
+	}
+}
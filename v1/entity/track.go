@@ -0,0 +1,136 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Tracked may be embedded by value in an entity to track changes to it.
+// When an entity that embeds Tracked is fetched or stored, the values of
+// its columns are recorded. The columns that have changed since can then
+// be determined, which allows updates to write only those columns.
+type Tracked struct {
+	snapshot map[string]interface{}
+}
+
+func (t *Tracked) tracked() *Tracked {
+	return t
+}
+
+type tracker interface {
+	tracked() *Tracked
+}
+
+// snapshotter is implemented by column values which are not stable when
+// they are provided to the driver, and so produce another value to record.
+type snapshotter interface {
+	snapshot() interface{}
+}
+
+// Snapshot records the current column values of a tracked entity. Entities
+// which do not embed Tracked are ignored.
+func (m *FieldMapper) Snapshot(entity interface{}) {
+	t, ok := entity.(tracker)
+	if !ok {
+		return
+	}
+	_, cols := m.Columns(entity)
+	snap := make(map[string]interface{}, len(cols.Cols))
+	for i, c := range cols.Cols {
+		snap[c] = snapshotValue(cols.Vals[i])
+	}
+	t.tracked().snapshot = snap
+}
+
+// Forget discards the snapshot of a tracked entity, if it has one.
+func (m *FieldMapper) Forget(entity interface{}) {
+	if t, ok := entity.(tracker); ok {
+		t.tracked().snapshot = nil
+	}
+}
+
+// Dirty produces the columns of a tracked entity which have changed since
+// its snapshot was recorded, sorted by name. If the entity is not tracked
+// or no snapshot has been recorded, the second result is false, in which
+// case every column must be considered dirty.
+func (m *FieldMapper) Dirty(entity interface{}) ([]string, bool) {
+	t, ok := entity.(tracker)
+	if !ok {
+		return nil, false
+	}
+	snap := t.tracked().snapshot
+	if snap == nil {
+		return nil, false
+	}
+
+	dirty := []string{}
+	_, cols := m.Columns(entity)
+	for i, c := range cols.Cols {
+		v, ok := snap[c]
+		if !ok || !equalValues(v, snapshotValue(cols.Vals[i])) {
+			dirty = append(dirty, c)
+		}
+	}
+
+	sort.Strings(dirty)
+	return dirty, true
+}
+
+// snapshotValue produces a copy of a column value which is not affected by
+// subsequent changes to the entity it was taken from. Pointers are replaced
+// by a copy of the value they point to, so that changes made through them
+// are detected.
+func snapshotValue(v interface{}) interface{} {
+	if c, ok := v.(snapshotter); ok {
+		return snapshotValue(c.snapshot())
+	}
+	if r := reflect.ValueOf(v); r.Kind() == reflect.Ptr && r.IsNil() {
+		return nil
+	}
+	if c, ok := v.(driver.Valuer); ok {
+		if x, err := c.Value(); err == nil {
+			v = x
+		}
+	}
+	switch c := v.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return append([]byte(nil), c...)
+	}
+	r := reflect.ValueOf(v)
+	switch r.Kind() {
+	case reflect.Ptr:
+		if r.IsNil() {
+			return nil
+		}
+		return snapshotValue(r.Elem().Interface())
+	case reflect.Slice:
+		if r.IsNil() {
+			return v
+		}
+		d := reflect.MakeSlice(r.Type(), r.Len(), r.Len())
+		reflect.Copy(d, r)
+		return d.Interface()
+	case reflect.Map:
+		if r.IsNil() {
+			return v
+		}
+		d := reflect.MakeMapWithSize(r.Type(), r.Len())
+		for it := r.MapRange(); it.Next(); {
+			d.SetMapIndex(it.Key(), it.Value())
+		}
+		return d.Interface()
+	}
+	return v
+}
+
+func equalValues(a, b interface{}) bool {
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		return ok && ta.Equal(tb)
+	}
+	return reflect.DeepEqual(a, b)
+}
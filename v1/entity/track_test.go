@@ -0,0 +1,86 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracking(t *testing.T) {
+	m := NewFieldMapper()
+	now := time.Now()
+
+	_, ok := m.Dirty(&testEntity{})
+	assert.False(t, ok, "Untracked entities have no dirty columns")
+
+	e := &trackedEntity{A: "AAA", B: []byte("BBB"), C: now}
+	_, ok = m.Dirty(e)
+	assert.False(t, ok, "Entities without a snapshot have no dirty columns")
+
+	m.Snapshot(e)
+	d, ok := m.Dirty(e)
+	if assert.True(t, ok) {
+		assert.Equal(t, []string{}, d)
+	}
+
+	e.B[0] = 'X'    // modified in place
+	e.C = now.UTC() // equivalent time
+	e.D = 0         // still empty
+	d, ok = m.Dirty(e)
+	if assert.True(t, ok) {
+		assert.Equal(t, []string{"b"}, d)
+	}
+
+	e.C = now.Add(time.Second)
+	e.D = 1
+	d, ok = m.Dirty(e)
+	if assert.True(t, ok) {
+		assert.Equal(t, []string{"b", "c", "d"}, d)
+	}
+
+	m.Snapshot(e)
+	d, ok = m.Dirty(e)
+	if assert.True(t, ok) {
+		assert.Equal(t, []string{}, d)
+	}
+
+	m.Forget(e)
+	_, ok = m.Dirty(e)
+	assert.False(t, ok)
+}
+
+type pointerEntity struct {
+	Tracked
+	A string     `db:"a,pk"`
+	B *string    `db:"b"`
+	C *time.Time `db:"c"`
+}
+
+func TestTrackingPointers(t *testing.T) {
+	m := NewFieldMapper()
+	name, now := "Original", time.Now()
+
+	e := &pointerEntity{A: "AAA", B: &name, C: &now}
+	m.Snapshot(e)
+
+	*e.B = "Changed" // modified through the pointer
+	d, ok := m.Dirty(e)
+	if assert.True(t, ok) {
+		assert.Equal(t, []string{"b"}, d)
+	}
+
+	m.Snapshot(e)
+	other := "Changed"
+	e.B = &other // a different pointer to the same value
+	d, ok = m.Dirty(e)
+	if assert.True(t, ok) {
+		assert.Equal(t, []string{}, d)
+	}
+
+	e.B, e.C = nil, nil
+	d, ok = m.Dirty(e)
+	if assert.True(t, ok) {
+		assert.Equal(t, []string{"b", "c"}, d)
+	}
+}
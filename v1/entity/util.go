@@ -1,9 +1,16 @@
 package entity
 
 import (
+	"database/sql"
+	"database/sql/driver"
 	"reflect"
 )
 
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
 // implemented by values that can express their zeronoess/emptiness
 type Zeroer interface {
 	IsZero() bool
@@ -41,3 +48,13 @@ func isNil(v reflect.Value) bool {
 		return false
 	}
 }
+
+// nullable determines whether a field of the provided type can represent
+// NULL, either because it is a pointer or because it scans and produces
+// values itself, like sql.NullTime.
+func nullable(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		return true
+	}
+	return t.Implements(valuerType) && reflect.PtrTo(t).Implements(scannerType)
+}
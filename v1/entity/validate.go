@@ -0,0 +1,236 @@
+package entity
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bww/go-dbx/v1"
+)
+
+// CheckTag is the struct tag which declares the rules a field is validated
+// against. Rules are separated by semicolons, for example:
+//
+//	Status string `db:"status" check:"required;max=16;enum=draft|published"`
+//	Slug   string `db:"slug" check:"regex=^[a-z0-9-]+$"`
+//
+// Supported rules are:
+//
+//   - required: the field must not be empty
+//   - max=N: the length of a string, in characters, or of a slice or map must not exceed N
+//   - enum=A|B|C: the value must be one of those listed
+//   - regex=EXPR: the value must match the regular expression
+//
+// Rules other than required are not applied to empty values. Columns which
+// are generated by the database are not required, since they are assigned
+// when an entity is written.
+const CheckTag = "check"
+
+const (
+	ruleRequired = "required"
+	ruleMax      = "max"
+	ruleEnum     = "enum"
+	ruleRegex    = "regex"
+)
+
+// Validator is implemented by entities which validate themselves. Validate
+// is invoked after the rules declared by the fields of an entity have been
+// checked. If it returns a *ValidationError, its fields are combined with
+// those that failed the declared rules.
+type Validator interface {
+	Validate() error
+}
+
+// FieldError describes a field which failed validation.
+type FieldError struct {
+	Path    string // the column path of the field; empty if the entity as a whole is invalid
+	Rule    string // the rule which was not satisfied, if any
+	Message string
+}
+
+func (e FieldError) String() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// ValidationError is produced when an entity is invalid. It describes every
+// field which failed validation.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	b := &strings.Builder{}
+	b.WriteString("Invalid entity: ")
+	for i, f := range e.Fields {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(f.String())
+	}
+	return b.String()
+}
+
+type fieldCheck struct {
+	index []int
+	path  string
+	rules []check
+}
+
+type check struct {
+	name  string
+	max   int
+	enum  map[string]struct{}
+	regex *regexp.Regexp
+}
+
+// Validate checks an entity against the rules declared by its fields and, if
+// it implements Validator, its own validation. If the entity is invalid a
+// *ValidationError is returned. A malformed rule produces ErrInvalidCheck.
+func (m *FieldMapper) Validate(entity interface{}) error {
+	e := reflect.ValueOf(entity)
+	checks, err := m.checksForType(e.Type())
+	if err != nil {
+		return err
+	}
+
+	verr := &ValidationError{}
+	for _, c := range checks {
+		v, ok := FieldByIndexesRO(e, c.index)
+		if !ok {
+			v = reflect.Value{}
+		}
+		for _, r := range c.rules {
+			if msg := r.apply(v); msg != "" {
+				verr.Fields = append(verr.Fields, FieldError{Path: c.path, Rule: r.name, Message: msg})
+			}
+		}
+	}
+
+	if val, ok := entity.(Validator); ok {
+		err := val.Validate()
+		if cerr, ok := err.(*ValidationError); ok {
+			verr.Fields = append(verr.Fields, cerr.Fields...)
+		} else if err != nil {
+			verr.Fields = append(verr.Fields, FieldError{Message: err.Error()})
+		}
+	}
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+func (m *FieldMapper) checksForType(typ reflect.Type) ([]fieldCheck, error) {
+	if v, ok := m.checks.Load(typ); ok {
+		return v.([]fieldCheck), nil
+	}
+
+	var checks []fieldCheck
+	for _, f := range m.fieldsWithTag(typ, CheckTag) {
+		rules, err := parseChecks(f.Field.Tag.Get(CheckTag))
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := f.Options[optionGenerated]; ok {
+			rules = withoutRule(rules, ruleRequired)
+		}
+		if len(rules) > 0 {
+			checks = append(checks, fieldCheck{index: f.Index, path: f.Path, rules: rules})
+		}
+	}
+
+	m.checks.Store(typ, checks)
+	return checks, nil
+}
+
+func parseChecks(tag string) ([]check, error) {
+	var rules []check
+	for _, e := range strings.Split(tag, ";") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(e, "=")
+		c := check{name: name}
+		switch name {
+		case ruleRequired:
+		case ruleMax:
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%w: %s", dbx.ErrInvalidCheck, e)
+			}
+			c.max = n
+		case ruleEnum:
+			c.enum = make(map[string]struct{})
+			for _, x := range strings.Split(arg, "|") {
+				c.enum[x] = struct{}{}
+			}
+		case ruleRegex:
+			x, err := regexp.Compile(arg)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", dbx.ErrInvalidCheck, e, err)
+			}
+			c.regex = x
+		default:
+			return nil, fmt.Errorf("%w: %s", dbx.ErrInvalidCheck, e)
+		}
+		rules = append(rules, c)
+	}
+	return rules, nil
+}
+
+func withoutRule(rules []check, name string) []check {
+	res := rules[:0]
+	for _, e := range rules {
+		if e.name != name {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// apply checks a value against a rule. If the value does not satisfy the
+// rule, a message describing the problem is returned.
+func (c check) apply(v reflect.Value) string {
+	empty := !v.IsValid() || isEmptyValue(v.Interface(), v)
+	if c.name == ruleRequired {
+		if empty {
+			return "is required"
+		}
+		return ""
+	} else if empty {
+		return ""
+	}
+
+	v = reflect.Indirect(v)
+	switch c.name {
+	case ruleMax:
+		var n int
+		switch v.Kind() {
+		case reflect.String:
+			n = len([]rune(v.String()))
+		case reflect.Slice, reflect.Array, reflect.Map:
+			n = v.Len()
+		default:
+			return ""
+		}
+		if n > c.max {
+			return fmt.Sprintf("exceeds maximum length of %d", c.max)
+		}
+	case ruleEnum:
+		if _, ok := c.enum[fmt.Sprint(v.Interface())]; !ok {
+			return "is not an allowed value"
+		}
+	case ruleRegex:
+		if !c.regex.MatchString(fmt.Sprint(v.Interface())) {
+			return "does not match the required format"
+		}
+	}
+	return ""
+}
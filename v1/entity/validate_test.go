@@ -0,0 +1,100 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bww/go-dbx/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+type checkedEntity struct {
+	A string   `db:"a,pk"`
+	B string   `db:"b" check:"required;max=4"`
+	C string   `db:"c" check:"enum=x|y"`
+	D *string  `db:"d" check:"regex=^[a-z]+$"`
+	E []string `db:"e" check:"max=2"`
+	F int      `db:"f" check:"enum=1|2|3"`
+	G string   `check:"required"` // not mapped
+	H bool     `db:"-"`
+	I int      `db:"i,generated" check:"required"` // assigned by the database
+}
+
+func (e *checkedEntity) Validate() error {
+	if e.H {
+		return errors.New("H is set")
+	}
+	return nil
+}
+
+type badCheckEntity struct {
+	A string `db:"a" check:"max=many"`
+}
+
+func TestValidate(t *testing.T) {
+	m := NewFieldMapper()
+	lower, upper := "abc", "ABC"
+
+	tests := []struct {
+		Entity interface{}
+		Expect []FieldError
+		Error  error
+	}{
+		{
+			&checkedEntity{B: "ok", C: "x", D: &lower, E: []string{"a"}, F: 2},
+			nil,
+			nil,
+		},
+		{
+			&checkedEntity{B: "ok"}, // empty values are only checked when required
+			nil,
+			nil,
+		},
+		{
+			&checkedEntity{B: "ñaña"}, // length is in characters
+			nil,
+			nil,
+		},
+		{
+			&checkedEntity{},
+			[]FieldError{
+				{Path: "b", Rule: "required", Message: "is required"},
+			},
+			nil,
+		},
+		{
+			&checkedEntity{B: "Too long", C: "z", D: &upper, E: []string{"a", "b", "c"}, F: 4, H: true},
+			[]FieldError{
+				{Path: "b", Rule: "max", Message: "exceeds maximum length of 4"},
+				{Path: "c", Rule: "enum", Message: "is not an allowed value"},
+				{Path: "d", Rule: "regex", Message: "does not match the required format"},
+				{Path: "e", Rule: "max", Message: "exceeds maximum length of 2"},
+				{Path: "f", Rule: "enum", Message: "is not an allowed value"},
+				{Message: "H is set"},
+			},
+			nil,
+		},
+		{
+			&badCheckEntity{A: "A"},
+			nil,
+			dbx.ErrInvalidCheck,
+		},
+	}
+	for _, e := range tests {
+		err := m.Validate(e.Entity)
+		if e.Error != nil {
+			assert.ErrorIs(t, err, e.Error)
+		} else if e.Expect == nil {
+			assert.Nil(t, err, fmt.Sprint(err))
+		} else {
+			var verr *ValidationError
+			if assert.ErrorAs(t, err, &verr) {
+				assert.Equal(t, e.Expect, verr.Fields)
+			}
+		}
+	}
+
+	err := m.Validate(&checkedEntity{C: "z"})
+	assert.EqualError(t, err, "Invalid entity: b: is required; c: is not an allowed value")
+}
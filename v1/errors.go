@@ -13,4 +13,12 @@ var (
 	ErrNotAStruct         = errors.New("Not a struct")
 	ErrMissingField       = errors.New("Missing field")
 	ErrDriverNotSupported = errors.New("Driver not supported")
+	ErrCrossTenant        = errors.New("Entity belongs to another tenant")
+	ErrStopIteration      = errors.New("Stop iteration")
+	ErrConflict           = errors.New("Entity was modified concurrently")
+	ErrInvalidCheck       = errors.New("Invalid check rule")
+	ErrNoCipher           = errors.New("No cipher is configured")
+	ErrInvalidCiphertext  = errors.New("Invalid ciphertext")
+	ErrKeyNotFound        = errors.New("Encryption key not found")
+	ErrNoTable            = errors.New("No table is declared")
 )
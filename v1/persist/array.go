@@ -0,0 +1,42 @@
+package persist
+
+import (
+	"database/sql/driver"
+	"reflect"
+
+	"github.com/lib/pq"
+)
+
+// bindArrays wraps query arguments which are slices or arrays so that they
+// are bound as Postgres arrays, which allows them to be used in conditions
+// like {@any(id, $1)}. Byte slices, including named types like net.IP, and
+// arguments which implement driver.Valuer are provided as-is.
+func bindArrays(args []interface{}) []interface{} {
+	var bound []interface{}
+	for i, e := range args {
+		if !isArrayArg(e) {
+			continue
+		}
+		if bound == nil {
+			bound = append([]interface{}(nil), args...)
+		}
+		bound[i] = pq.Array(e)
+	}
+	if bound == nil {
+		return args
+	}
+	return bound
+}
+
+func isArrayArg(v interface{}) bool {
+	switch v.(type) {
+	case nil, driver.Valuer:
+		return false
+	}
+	switch t := reflect.TypeOf(v); t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return t.Elem().Kind() != reflect.Uint8 // bytes, like net.IP or json.RawMessage
+	default:
+		return false
+	}
+}
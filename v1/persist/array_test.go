@@ -0,0 +1,23 @@
+package persist
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+type namedBytes []byte
+
+func TestBindArrays(t *testing.T) {
+	raw := json.RawMessage(`{"a":1}`)
+	ip := net.ParseIP("10.0.0.1")
+	ids := []string{"a", "b"}
+
+	args := []interface{}{nil, "x", []byte("y"), raw, ip, namedBytes("z"), [4]byte{1, 2, 3, 4}}
+	assert.Equal(t, args, bindArrays(args))
+
+	assert.Equal(t, []interface{}{"x", pq.Array(ids), raw}, bindArrays([]interface{}{"x", ids, raw}))
+}
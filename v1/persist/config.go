@@ -3,6 +3,8 @@ package persist
 import (
 	"fmt"
 	"sync"
+
+	"github.com/bww/go-dbx/v1/entity"
 )
 
 var cascadeOptionDeprecatedWarningOnce sync.Once
@@ -26,6 +28,9 @@ type Config struct {
 	StoreRelated  bool
 	DeleteRelated bool
 	Upsert        bool
+	UpsertOptions []entity.GenerateOption // how upserts handle conflicts; see entity.Generator.Upsert
+	Tenant        interface{}             // when non-nil, operations are scoped to this tenant
+	Cursor        int                     // when positive, iterate in batches of this size using a cursor
 	Params        map[string]interface{}
 }
 
@@ -44,9 +49,13 @@ func UseConfig(c Config) Option {
 	}
 }
 
-func Upsert() Option {
+// Upsert configures Store to upsert entities. Options describe how conflicts
+// are handled, for example by specifying a conflict target other than the
+// primary key or a merge behavior for certain columns.
+func Upsert(opts ...entity.GenerateOption) Option {
 	return func(c Config) Config {
 		c.Upsert = true
+		c.UpsertOptions = opts
 		return c
 	}
 }
@@ -58,6 +67,26 @@ func Insert() Option {
 	}
 }
 
+// Tenant scopes operations to the specified tenant. Entities which declare
+// a tenant column have it written on store and matched on fetch and delete.
+// A nil tenant removes any scoping.
+func Tenant(v interface{}) Option {
+	return func(c Config) Config {
+		c.Tenant = v
+		return c
+	}
+}
+
+// Cursor configures iteration via Each to read results from a server-side
+// cursor in batches of n rows when the persister is operating in a
+// transaction. Outside a transaction, results are read from a single query.
+func Cursor(n int) Option {
+	return func(c Config) Config {
+		c.Cursor = n
+		return c
+	}
+}
+
 func Cascade(on bool) Option {
 	return func(c Config) Config {
 		warnCascadeOptionDeprecated()
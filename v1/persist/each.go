@@ -0,0 +1,170 @@
+package persist
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/bww/go-dbx/v1"
+	"github.com/bww/go-dbx/v1/entity"
+	"github.com/bww/go-dbx/v1/errors"
+)
+
+var cursorSeq uint64
+
+// Each selects entities of the provided type using a PQL query and invokes
+// fn with each one as it is scanned, so the result set is never held in
+// memory all at once. Every row is scanned into a newly allocated entity,
+// which is passed to fn as a pointer.
+//
+// Iteration stops at the first error returned by fn, which is returned by
+// Each, unless that error is dbx.ErrStopIteration, in which case iteration
+// stops and Each returns nil.
+//
+// When the persister is configured with a cursor batch size and is operating
+// in a transaction, results are read in batches from a server-side cursor.
+func (p *persister) Each(typ reflect.Type, fn func(interface{}) error, query string, args ...interface{}) error {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return dbx.ErrNotAStruct
+	}
+
+	cols := p.fm.ColumnsForType(typ, entity.ExcludeFromPQL)
+	sql, err := p.compile(typ, cols, query)
+	if err != nil {
+		return err
+	}
+
+	err = p.each(typ, sql, bindArrays(args), fn)
+	if err == dbx.ErrStopIteration {
+		return nil
+	}
+	return err
+}
+
+func (p *persister) each(ctype reflect.Type, sql string, args []interface{}, fn func(interface{}) error) error {
+	var rel FetchRelatedPersister
+	if p.conf.FetchRelated {
+		if pst, ok := p.reg.Get(ctype); ok {
+			if c, ok := pst.(FetchRelatedPersister); ok {
+				rel = c
+			}
+		}
+	}
+
+	if p.conf.Cursor > 0 && dbx.IsTx(p.Context) {
+		return p.eachWithCursor(ctype, rel, sql, args, fn)
+	}
+
+	raws, err := p.Context.Queryx(sql, args...)
+	if err != nil {
+		return errors.NewWithSQL(err, sql)
+	}
+
+	rows := newRows(raws, p.fm)
+	defer func() {
+		if rows != nil {
+			rows.Close()
+		}
+	}()
+
+	_, err = p.scanEach(rows, ctype, rel, sql, fn)
+	if err != nil {
+		return err
+	}
+
+	err, rows = rows.Close(), nil
+	if err != nil {
+		return errors.NewWithSQL(err, sql)
+	}
+
+	return nil
+}
+
+// eachWithCursor declares a server-side cursor for a query and fetches its
+// results in batches. Cursors only exist in the scope of a transaction.
+func (p *persister) eachWithCursor(ctype reflect.Type, rel FetchRelatedPersister, sql string, args []interface{}, fn func(interface{}) error) error {
+	name := fmt.Sprintf("dbx_cursor_%d", atomic.AddUint64(&cursorSeq, 1))
+
+	declare := "DECLARE " + name + " NO SCROLL CURSOR FOR " + sql
+	_, err := p.Context.Exec(declare, args...)
+	if err != nil {
+		return errors.NewWithSQL(err, declare)
+	}
+
+	closed := false
+	defer func() {
+		if !closed {
+			p.Context.Exec("CLOSE " + name)
+		}
+	}()
+
+	var rows *Rows
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM %s", p.conf.Cursor, name)
+	for {
+		raws, err := p.Context.Queryx(fetch)
+		if err != nil {
+			return errors.NewWithSQL(err, fetch)
+		}
+		if rows == nil {
+			rows = newRows(raws, p.fm)
+		} else {
+			rows.reset(raws) // retain the traversal computed for the first batch
+		}
+
+		n, err := p.scanEach(rows, ctype, rel, fetch, fn)
+		if cerr := rows.Close(); err == nil && cerr != nil {
+			err = errors.NewWithSQL(cerr, fetch)
+		}
+		if err != nil {
+			return err
+		}
+		if n < p.conf.Cursor {
+			break
+		}
+	}
+
+	stmt := "CLOSE " + name
+	_, err = p.Context.Exec(stmt)
+	closed = true
+	if err != nil {
+		return errors.NewWithSQL(err, stmt)
+	}
+
+	return nil
+}
+
+// scanEach scans every remaining row into a new entity and hands it to fn,
+// producing the number of rows that were scanned.
+func (p *persister) scanEach(rows *Rows, ctype reflect.Type, rel FetchRelatedPersister, sql string, fn func(interface{}) error) (int, error) {
+	var n int
+	for rows.Next() {
+		elem := reflect.New(ctype).Interface()
+		err := rows.ScanStruct(elem)
+		if err != nil {
+			return n, errors.NewWithSQL(err, sql)
+		}
+		p.fm.Snapshot(elem)
+		n++
+		if rel != nil {
+			err = rel.FetchRelated(p, elem)
+			if err != nil {
+				return n, err
+			}
+		}
+		err = p.afterFetch(elem)
+		if err != nil {
+			return n, err
+		}
+		err = fn(elem)
+		if err != nil {
+			return n, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return n, errors.NewWithSQL(err, sql)
+	}
+	return n, nil
+}
@@ -0,0 +1,66 @@
+package persist
+
+// BeforeStorer is implemented by entities which are notified before they
+// are stored. An error returned by the hook aborts the operation.
+type BeforeStorer interface {
+	BeforeStore(Persister) error
+}
+
+// AfterStorer is implemented by entities which are notified after they have
+// been stored.
+type AfterStorer interface {
+	AfterStore(Persister) error
+}
+
+// AfterFetcher is implemented by entities which are notified after they have
+// been fetched or selected.
+type AfterFetcher interface {
+	AfterFetch(Persister) error
+}
+
+// BeforeDeleter is implemented by entities which are notified before they
+// are deleted. An error returned by the hook aborts the operation.
+type BeforeDeleter interface {
+	BeforeDelete(Persister) error
+}
+
+// AfterDeleter is implemented by entities which are notified after they have
+// been deleted.
+type AfterDeleter interface {
+	AfterDelete(Persister) error
+}
+
+func (p *persister) beforeStore(ent interface{}) error {
+	if h, ok := ent.(BeforeStorer); ok {
+		return h.BeforeStore(p)
+	}
+	return nil
+}
+
+func (p *persister) afterStore(ent interface{}) error {
+	if h, ok := ent.(AfterStorer); ok {
+		return h.AfterStore(p)
+	}
+	return nil
+}
+
+func (p *persister) afterFetch(ent interface{}) error {
+	if h, ok := ent.(AfterFetcher); ok {
+		return h.AfterFetch(p)
+	}
+	return nil
+}
+
+func (p *persister) beforeDelete(ent interface{}) error {
+	if h, ok := ent.(BeforeDeleter); ok {
+		return h.BeforeDelete(p)
+	}
+	return nil
+}
+
+func (p *persister) afterDelete(ent interface{}) error {
+	if h, ok := ent.(AfterDeleter); ok {
+		return h.AfterDelete(p)
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package persist
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/bww/go-dbx/v1"
+	"github.com/bww/go-dbx/v1/entity"
+	"github.com/bww/go-dbx/v1/errors"
+	"github.com/lib/pq"
+)
+
+// Implemented by contexts, like *dbx.DB, which can run a handler in a
+// transaction they manage.
+type transactor interface {
+	Transaction(dbx.TransactionHandler) error
+}
+
+// StoreMany inserts a slice of entities. The entities are provided as a
+// slice, or a pointer to a slice, of structs or struct pointers. Primary
+// keys are generated for entities that do not have one, as they are by
+// Store; however, every entity is inserted, regardless of whether it has
+// a key already, unless the persister is configured to upsert.
+//
+// When configured to upsert, entities are written via batches of multi-row
+// upserts, which update existing rows that conflict on the primary key. A
+// slice must not contain more than one entity with the same key in this
+// case. The batches are written in a transaction when possible, so that no
+// entities are stored if any of them belong to another tenant.
+//
+// When the persister operates on Postgres, rows are streamed to the table
+// via COPY FROM STDIN. COPY requires a transaction: if the persister is not
+// operating in one but its context is able to create one, as is the case
+// for *dbx.DB, a transaction is used for the duration of the copy. In other
+// cases entities are inserted via batches of multi-row INSERT statements.
+//
+// Related entities are not stored, regardless of configuration.
+func (p *persister) StoreMany(table string, ents interface{}) error {
+	val := reflect.Indirect(reflect.ValueOf(ents))
+	if val.Kind() != reflect.Slice {
+		return dbx.ErrInvalidField
+	}
+	table, err := tableFor(table, val.Type().Elem())
+	if err != nil {
+		return err
+	}
+
+	now := dbx.Now()
+	elems := make([]interface{}, val.Len())
+	for i := range elems {
+		e := val.Index(i)
+		if e.Kind() != reflect.Ptr {
+			e = e.Addr()
+		}
+		if e.IsNil() {
+			return dbx.ErrInvalidField
+		}
+		err = p.prepare(e.Interface())
+		if err != nil {
+			return err
+		}
+		_, err = p.assignKeys(e.Interface())
+		if err != nil {
+			return err
+		}
+		err = p.initVersion(e.Interface())
+		if err != nil {
+			return err
+		}
+		err = p.stamp(e.Interface(), true, now)
+		if err != nil {
+			return err
+		}
+		err = p.fm.Validate(e.Interface())
+		if err != nil {
+			return err
+		}
+		elems[i] = e.Interface()
+	}
+	if len(elems) == 0 {
+		return nil
+	}
+
+	err = p.storeMany(table, elems)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range elems {
+		p.fm.Snapshot(e)
+	}
+	for _, e := range elems {
+		err := p.afterStore(e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *persister) storeMany(table string, elems []interface{}) error {
+	mode := writeInsert
+	if p.conf.Upsert {
+		mode = writeUpsert
+	}
+
+	// values can only be reliably read back from single-row statements, so
+	// entities whose writes return values are written one at a time
+	if p.returns(reflect.TypeOf(elems[0]), mode) {
+		for _, e := range elems {
+			err := p.store(table, e, nil, mode)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if p.conf.Upsert {
+		return p.upsertMany(table, elems)
+	}
+
+	if copyable(p.Context) {
+		cols, rows := p.fm.Tabulate(elems)
+		if tx, ok := p.Context.(dbx.Tx); ok {
+			return copyIn(tx, table, cols, rows)
+		} else if db, ok := p.Context.(transactor); ok {
+			return db.Transaction(func(cxt dbx.Context) error {
+				return copyIn(cxt, table, cols, rows)
+			})
+		}
+	}
+
+	for _, e := range p.gen.InsertMany(table, elems) {
+		_, err := p.Context.Exec(e.SQL, e.Args...)
+		if err != nil {
+			return errors.NewWithSQL(err, e.SQL)
+		}
+	}
+
+	return nil
+}
+
+// copyable determines whether a context is able to perform COPY FROM STDIN,
+// which must be supported by the driver and requires a prepared statement.
+func copyable(cxt dbx.Context) bool {
+	n, ok := cxt.(dbx.DriverNamer)
+	if !ok || n.DriverName() != "postgres" {
+		return false
+	}
+	_, ok = cxt.(dbx.Preparer)
+	return ok
+}
+
+// copyIn streams rows into a table via COPY FROM STDIN. The context must be
+// a transaction.
+func copyIn(cxt dbx.Context, table string, cols []string, rows [][]interface{}) error {
+	var sql string
+	if x := strings.Index(table, "."); x > 0 {
+		sql = pq.CopyInSchema(table[:x], table[x+1:], cols...)
+	} else {
+		sql = pq.CopyIn(table, cols...)
+	}
+
+	stmt, err := cxt.(dbx.Preparer).Prepare(sql)
+	if err != nil {
+		return errors.NewWithSQL(err, sql)
+	}
+	defer stmt.Close()
+
+	for _, e := range rows {
+		_, err = stmt.Exec(e...)
+		if err != nil {
+			return errors.NewWithSQL(err, sql)
+		}
+	}
+
+	_, err = stmt.Exec() // flush buffered rows
+	if err != nil {
+		return errors.NewWithSQL(err, sql)
+	}
+
+	err = stmt.Close()
+	if err != nil {
+		return errors.NewWithSQL(err, sql)
+	}
+
+	return nil
+}
+
+// upsertOptions produces the options with which upserts are generated,
+// which restrict updates to rows owned by the tenant, if any.
+func (p *persister) upsertOptions(tcols *entity.Columns) []entity.GenerateOption {
+	opts := make([]entity.GenerateOption, 0, len(p.conf.UpsertOptions)+1)
+	opts = append(opts, p.conf.UpsertOptions...)
+	return append(opts, entity.WithWhere(tcols))
+}
+
+// upsertMany writes entities via batches of multi-row upserts. Batches are
+// written atomically: if the persister is operating in a transaction, they
+// are written under a savepoint; otherwise, if its context is able to create
+// a transaction, they are written in one. When a batch is found to conflict
+// with rows owned by another tenant, the writes are rolled back and
+// ErrCrossTenant is returned.
+func (p *persister) upsertMany(table string, elems []interface{}) error {
+	if tx, ok := p.Context.(dbx.Tx); ok {
+		return savepoint(tx, "dbx_upsert_many", func() error {
+			return p.upsertBatches(tx, table, elems)
+		})
+	} else if db, ok := p.Context.(transactor); ok {
+		return db.Transaction(func(cxt dbx.Context) error {
+			return p.upsertBatches(cxt, table, elems)
+		})
+	} else {
+		return p.upsertBatches(p.Context, table, elems)
+	}
+}
+
+func (p *persister) upsertBatches(cxt dbx.Context, table string, elems []interface{}) error {
+	tcols := p.tenantColumns(reflect.TypeOf(elems[0]))
+	for _, e := range p.gen.UpsertMany(table, elems, p.upsertOptions(tcols)...) {
+		res, err := cxt.Exec(e.SQL, e.Args...)
+		if err != nil {
+			return errors.NewWithSQL(err, e.SQL)
+		}
+		if tcols != nil && !e.DoNothing { // with DO NOTHING, fewer rows are expected
+			n, err := res.RowsAffected()
+			if err != nil {
+				return errors.NewWithSQL(err, e.SQL)
+			}
+			if n < int64(e.Rows) { // some conflicting rows belong to another tenant
+				return dbx.ErrCrossTenant
+			}
+		}
+	}
+	return nil
+}
+
+// savepoint runs a function under a savepoint in a transaction, which is
+// rolled back to if the function fails.
+func savepoint(tx dbx.Tx, name string, f func() error) error {
+	_, err := tx.Exec("SAVEPOINT " + name)
+	if err != nil {
+		return err
+	}
+	err = f()
+	if err != nil {
+		if _, rerr := tx.Exec("ROLLBACK TO SAVEPOINT " + name); rerr != nil {
+			return rerr
+		}
+		return err
+	}
+	_, err = tx.Exec("RELEASE SAVEPOINT " + name)
+	return err
+}
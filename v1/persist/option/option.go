@@ -22,6 +22,12 @@ func StoreRelated(on bool) Option {
 func DeleteRelated(on bool) Option {
 	return persist.DeleteRelated(on)
 }
+func Tenant(v interface{}) Option {
+	return persist.Tenant(v)
+}
+func Cursor(n int) Option {
+	return persist.Cursor(n)
+}
 func Params(p map[string]interface{}) Option {
 	return persist.Params(p)
 }
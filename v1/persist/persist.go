@@ -30,18 +30,28 @@ type DeleteReferencesPersister interface {
 	DeleteReferences(Persister, interface{}) error
 }
 
+// Persister stores and fetches entities. Methods which accept a table name
+// use the table declared by the entity's type when it is empty; see
+// entity.Tabler.
 type Persister interface {
 	dbx.Context
 	WithContext(dbx.Context) Persister
 	WithOptions(...Option) Persister
 	Config() Config
+	FieldMapper() *entity.FieldMapper
 	Param(name string) interface{}
 	Store(string, interface{}, []string) error
+	Insert(string, interface{}) error
+	Update(string, interface{}, []string) error
+	StoreMany(string, interface{}) error
 	Fetch(string, interface{}, interface{}) error
 	Count(string, ...interface{}) (int, error)
 	Select(interface{}, string, ...interface{}) error
+	Each(reflect.Type, func(interface{}) error, string, ...interface{}) error
 	Delete(string, interface{}) error
 	DeleteWithID(string, reflect.Type, interface{}) error
+	HardDelete(string, interface{}) error
+	Restore(string, interface{}) error
 }
 
 type persister struct {
@@ -90,6 +100,10 @@ func (p *persister) Config() Config {
 	return p.conf
 }
 
+func (p *persister) FieldMapper() *entity.FieldMapper {
+	return p.fm
+}
+
 func (p *persister) Param(name string) interface{} {
 	if m := p.conf.Params; m != nil {
 		return m[name]
@@ -98,7 +112,7 @@ func (p *persister) Param(name string) interface{} {
 }
 
 func (p *persister) Exec(query string, args ...interface{}) (sql.Result, error) {
-	r, err := p.Context.Exec(query, args...)
+	r, err := p.Context.Exec(query, bindArrays(args)...)
 	if err != nil {
 		return nil, errors.NewWithSQL(err, query)
 	}
@@ -106,26 +120,34 @@ func (p *persister) Exec(query string, args ...interface{}) (sql.Result, error)
 }
 
 func (p *persister) Fetch(table string, ent, id interface{}) error {
-	keys, _ := p.fm.Columns(ent)
-
-	if len(keys.Cols) != 1 {
-		return dbx.ErrInvalidKeyCount
+	table, err := tableFor(table, reflect.TypeOf(ent))
+	if err != nil {
+		return err
+	}
+	kcols, err := p.fm.KeyColumns(reflect.TypeOf(ent), id)
+	if err != nil {
+		return err
 	}
 
-	sql, args := p.gen.Select(table, ent, &entity.Columns{
-		Cols: keys.Cols,
-		Vals: []interface{}{id},
-	})
+	tcols := p.tenantColumns(reflect.TypeOf(ent))
+	sql, args := p.gen.Select(table, ent, kcols, entity.WithWhere(tcols), entity.WithWhere(p.liveColumns(reflect.TypeOf(ent))))
 
 	raw := p.Context.QueryRowx(sql, args...)
 	row := newRow(raw, p.fm)
-	err := row.ScanStruct(ent)
+	err = row.ScanStruct(ent)
 	if err == dbsql.ErrNoRows {
+		if tcols != nil {
+			if err := p.crossTenant(table, kcols, tcols); err != nil {
+				return err
+			}
+		}
 		return dbx.ErrNotFound
 	} else if err != nil {
 		return errors.NewWithSQL(err, sql)
 	}
 
+	p.fm.Snapshot(ent)
+
 	if p.conf.FetchRelated {
 		if pst, ok := p.reg.GetFor(ent); ok {
 			if c, ok := pst.(FetchRelatedPersister); ok {
@@ -137,13 +159,13 @@ func (p *persister) Fetch(table string, ent, id interface{}) error {
 		}
 	}
 
-	return nil
+	return p.afterFetch(ent)
 }
 
 func (p *persister) Count(query string, args ...interface{}) (int, error) {
 	var n int
 
-	err := p.Context.QueryRow(query, args...).Scan(&n)
+	err := p.Context.QueryRow(query, bindArrays(args)...).Scan(&n)
 	if err != nil {
 		return -1, errors.NewWithSQL(err, query)
 	}
@@ -174,16 +196,12 @@ func (p *persister) Select(ent interface{}, query string, args ...interface{}) e
 	}
 
 	cols := p.fm.ColumnsForType(typ, entity.ExcludeFromPQL)
-
-	prg, err := pql.Parse(query)
-	if err != nil {
-		return errors.NewWithSQL(err, query)
-	}
-	sql, err := prg.Text(pql.Context{Columns: cols})
+	sql, err := p.compile(typ, cols, query)
 	if err != nil {
-		return errors.NewWithSQL(err, query)
+		return err
 	}
 
+	args = bindArrays(args)
 	if many {
 		return p.selectMany(ent, val, cols, sql, args)
 	} else {
@@ -191,6 +209,23 @@ func (p *persister) Select(ent interface{}, query string, args ...interface{}) e
 	}
 }
 
+func (p *persister) compile(typ reflect.Type, cols []string, query string) (string, error) {
+	prg, err := pql.Parse(query)
+	if err != nil {
+		return "", errors.NewWithSQL(err, query)
+	}
+	table, _ := entity.TableForType(typ)
+	sql, err := prg.Text(pql.Context{
+		Table:   table,
+		Columns: cols,
+		Deleted: p.fm.DeletedForType(typ),
+	})
+	if err != nil {
+		return "", errors.NewWithSQL(err, query)
+	}
+	return sql, nil
+}
+
 func (p *persister) selectOne(ent interface{}, val reflect.Value, cols []string, sql string, args []interface{}) error {
 
 	raw := p.Context.QueryRowx(sql, args...)
@@ -202,6 +237,8 @@ func (p *persister) selectOne(ent interface{}, val reflect.Value, cols []string,
 		return errors.NewWithSQL(err, sql)
 	}
 
+	p.fm.Snapshot(ent)
+
 	if p.conf.FetchRelated {
 		if pst, ok := p.reg.Get(val.Type()); ok {
 			if c, ok := pst.(FetchRelatedPersister); ok {
@@ -213,7 +250,7 @@ func (p *persister) selectOne(ent interface{}, val reflect.Value, cols []string,
 		}
 	}
 
-	return nil
+	return p.afterFetch(ent)
 }
 
 func (p *persister) selectMany(ent interface{}, val reflect.Value, cols []string, sql string, args []interface{}) error {
@@ -221,18 +258,6 @@ func (p *persister) selectMany(ent interface{}, val reflect.Value, cols []string
 		return dbx.ErrNotAPointer
 	}
 
-	raws, err := p.Context.Queryx(sql, args...)
-	if err != nil {
-		return errors.NewWithSQL(err, sql)
-	}
-
-	rows := newRows(raws, p.fm)
-	defer func() {
-		if rows != nil {
-			rows.Close()
-		}
-	}()
-
 	eval := reflect.Indirect(val)
 	etype := eval.Type().Elem()
 	ctype := etype
@@ -241,84 +266,178 @@ func (p *persister) selectMany(ent interface{}, val reflect.Value, cols []string
 		ctype = etype.Elem()
 	}
 
-	var rel FetchRelatedPersister
-	if p.conf.FetchRelated {
-		if pst, ok := p.reg.Get(ctype); ok {
-			if c, ok := pst.(FetchRelatedPersister); ok {
-				rel = c
-			}
-		}
-	}
-
-	for rows.Next() {
-		elem := reflect.New(ctype)
-		eint := elem.Interface()
-		err := rows.ScanStruct(eint)
-		if err != nil {
-			return errors.NewWithSQL(err, sql)
-		}
-		if rel != nil {
-			err = rel.FetchRelated(p, eint)
-			if err != nil {
-				return err
-			}
-		}
+	err := p.each(ctype, sql, args, func(ent interface{}) error {
+		elem := reflect.ValueOf(ent)
 		if etype.Kind() != reflect.Ptr {
 			elem = reflect.Indirect(elem)
 		}
 		eval = reflect.Append(eval, elem)
-	}
-
-	err, rows = rows.Close(), nil
+		return nil
+	})
 	if err != nil {
-		return errors.NewWithSQL(err, sql)
+		return err
 	}
 
 	reflect.Indirect(val).Set(eval)
 	return nil
 }
 
+// The operations by which an entity may be written.
+type writeMode int
+
+const (
+	writeInsert writeMode = iota
+	writeUpdate
+	writeUpsert
+)
+
+// Store writes an entity, either inserting or updating it as appropriate.
+// If the entity implements entity.Newer, it decides which; otherwise an
+// entity with a zero primary key is inserted. When the persister is
+// configured to upsert, the entity is always upserted.
 func (p *persister) Store(table string, ent interface{}, cols []string) error {
-	var insert bool
+	table, err := tableFor(table, reflect.TypeOf(ent))
+	if err != nil {
+		return err
+	}
+	err = p.prepare(ent)
+	if err != nil {
+		return err
+	}
+
+	mode := writeUpsert
 	if !p.conf.Upsert {
-		keys, err := p.fm.Keys(ent)
+		mode, err = p.storeMode(table, ent)
 		if err != nil {
 			return err
 		}
-		for _, e := range keys.Vals {
-			if !e.IsValid() {
-				return dbx.ErrInvalidField
-			}
-			if e.IsZero() {
-				insert = true
-				break
-			}
+	}
+
+	return p.write(table, ent, cols, mode)
+}
+
+// Insert inserts an entity, generating a value for every primary key which
+// is zero.
+func (p *persister) Insert(table string, ent interface{}) error {
+	table, err := tableFor(table, reflect.TypeOf(ent))
+	if err != nil {
+		return err
+	}
+	err = p.prepare(ent)
+	if err != nil {
+		return err
+	}
+	_, err = p.assignKeys(ent)
+	if err != nil {
+		return err
+	}
+	return p.write(table, ent, nil, writeInsert)
+}
+
+// Update updates the named columns of an entity, or every column if none
+// are named. If no row is identified by the entity's keys, ErrNotFound is
+// returned.
+func (p *persister) Update(table string, ent interface{}, cols []string) error {
+	table, err := tableFor(table, reflect.TypeOf(ent))
+	if err != nil {
+		return err
+	}
+	err = p.prepare(ent)
+	if err != nil {
+		return err
+	}
+	return p.write(table, ent, cols, writeUpdate)
+}
+
+// prepare readies an entity to be written: hooks are run and its tenant is
+// assigned. The entity is validated later, once the persister has assigned
+// its keys and timestamps.
+func (p *persister) prepare(ent interface{}) error {
+	err := p.beforeStore(ent)
+	if err != nil {
+		return err
+	}
+	_, err = p.fm.Deleted(ent) // soft delete fields must be nullable
+	if err != nil {
+		return err
+	}
+	return p.assignTenant(ent)
+}
+
+// storeMode determines whether an entity should be inserted or updated, and
+// assigns its keys if it is to be inserted.
+func (p *persister) storeMode(table string, ent interface{}) (writeMode, error) {
+	if n, ok := ent.(entity.Newer); ok {
+		if !n.IsNew() {
+			return writeUpdate, nil
 		}
-		if insert {
-			if len(keys.Vals) != 1 {
-				return dbx.ErrInvalidKeyCount
-			}
-			if p.ids != nil {
-				keys.Vals[0].Set(p.ids()) // generate primary key
+		_, err := p.assignKeys(ent)
+		if err != nil {
+			return 0, err
+		}
+		return writeInsert, nil
+	}
+
+	insert, err := p.assignKeys(ent)
+	if err != nil {
+		return 0, err
+	}
+	if !insert {
+		// composite keys are generally assigned by the client, so their
+		// presence doesn't indicate whether the entity is new or not
+		keys, _ := p.fm.Columns(ent)
+		if len(keys.Cols) > 1 {
+			found, err := p.exists(table, keys)
+			if err != nil {
+				return 0, err
 			}
+			insert = !found
 		}
 	}
 
-	var sql string
-	var args []interface{}
-	if p.conf.Upsert {
-		sql, args = p.gen.Upsert(table, ent, cols)
-	} else if insert {
-		sql, args = p.gen.Insert(table, ent)
+	if insert {
+		return writeInsert, nil
 	} else {
-		sql, args = p.gen.Update(table, ent, cols)
+		return writeUpdate, nil
 	}
+}
 
-	_, err := p.Context.Exec(sql, args...)
-	if err != nil {
-		return errors.NewWithSQL(err, sql)
+func (p *persister) write(table string, ent interface{}, cols []string, mode writeMode) error {
+	// when an update of all columns is requested for a tracked entity, only
+	// the columns that have changed are written; possibly none at all, in
+	// which case the entity's row is only checked for
+	write := true
+	if mode == writeUpdate && cols == nil {
+		if dirty, ok := p.fm.Dirty(ent); ok {
+			write, cols = len(dirty) > 0, dirty
+		}
+	}
+
+	if write {
+		err := p.stamp(ent, mode != writeUpdate, dbx.Now())
+		if err != nil {
+			return err
+		}
+		err = p.fm.Validate(ent)
+		if err != nil {
+			return err
+		}
+		if cols != nil {
+			cols = p.withUpdated(reflect.TypeOf(ent), cols)
+		}
+		err = p.store(table, ent, cols, mode)
+		if err != nil {
+			return err
+		}
+	} else {
+		err := p.unchanged(table, ent)
+		if err != nil {
+			return err
+		}
 	}
 
+	p.fm.Snapshot(ent)
+
 	if p.conf.StoreRelated {
 		if pst, ok := p.reg.GetFor(ent); ok {
 			if c, ok := pst.(StoreRelatedPersister); ok {
@@ -336,16 +455,237 @@ func (p *persister) Store(table string, ent interface{}, cols []string) error {
 		}
 	}
 
+	return p.afterStore(ent)
+}
+
+// store writes an entity using the statement appropriate to the operation.
+// If the entity declares returning columns, the values returned for them are
+// scanned back into the entity.
+func (p *persister) store(table string, ent interface{}, cols []string, mode writeMode) error {
+	var sql string
+	var args []interface{}
+	typ := reflect.TypeOf(ent)
+	tcols := p.tenantColumns(typ)
+	versioned := p.versioned(typ)
+	if versioned && mode != writeUpdate {
+		err := p.initVersion(ent)
+		if err != nil {
+			return err
+		}
+	}
+	switch mode {
+	case writeUpsert:
+		sql, args = p.gen.Upsert(table, ent, cols, p.upsertOptions(tcols)...)
+	case writeInsert:
+		sql, args = p.gen.Insert(table, ent)
+	default:
+		sql, args = p.gen.Update(table, ent, cols, entity.WithWhere(tcols))
+	}
+
+	check := mode == writeUpdate || (mode == writeUpsert && tcols != nil)
+	n := int64(1) // rows affected, which is only determined when it matters
+	if p.returns(typ, mode) {
+		row := newRow(p.Context.QueryRowx(sql, args...), p.fm)
+		err := row.ScanStruct(ent)
+		if err == dbsql.ErrNoRows {
+			n = 0
+		} else if err != nil {
+			return errors.NewWithSQL(err, sql)
+		}
+	} else {
+		res, err := p.Context.Exec(sql, args...)
+		if err != nil {
+			return errors.NewWithSQL(err, sql)
+		}
+		if check {
+			n, err = res.RowsAffected()
+			if err != nil {
+				return errors.NewWithSQL(err, sql)
+			}
+		}
+	}
+
+	if check && n == 0 {
+		keys, _ := p.fm.Columns(ent)
+		if tcols != nil {
+			if err := p.crossTenant(table, keys, tcols); err != nil {
+				return err
+			}
+		}
+		if mode == writeUpdate {
+			return p.missing(table, keys, versioned)
+		}
+	}
+
+	// an upsert may either insert or update, so the version of the stored
+	// row is not known; the entity should be fetched again if it matters
+	if versioned && mode == writeUpdate {
+		return p.incrVersion(ent)
+	}
+
 	return nil
 }
 
+// unchanged is invoked in place of an update when nothing about an entity
+// has changed. No statement is needed, but the update must fail as it would
+// have if the entity's row does not exist or belongs to another tenant.
+func (p *persister) unchanged(table string, ent interface{}) error {
+	keys, _ := p.fm.Columns(ent)
+	tcols := p.tenantColumns(reflect.TypeOf(ent))
+	found, err := p.exists(table, keys, entity.WithWhere(tcols))
+	if err != nil {
+		return err
+	}
+	if found {
+		return nil
+	}
+	if tcols != nil {
+		if err := p.crossTenant(table, keys, tcols); err != nil {
+			return err
+		}
+	}
+	return dbx.ErrNotFound
+}
+
+// returns determines whether the statement which writes an entity returns
+// values that are scanned back into it. Upserts return creation times, since
+// a row that already exists keeps its own.
+func (p *persister) returns(typ reflect.Type, mode writeMode) bool {
+	if len(p.fm.ReturningForType(typ)) > 0 {
+		return true
+	}
+	return mode == writeUpsert && len(p.fm.CreatedForType(typ)) > 0
+}
+
+// missing is invoked when an update matched no rows. If the entity is
+// versioned and its row exists, it must have been modified since it was
+// read and ErrConflict is returned; otherwise the row does not exist and the
+// result is ErrNotFound.
+func (p *persister) missing(table string, keys *entity.Columns, versioned bool) error {
+	if versioned {
+		found, err := p.exists(table, keys)
+		if err != nil {
+			return err
+		}
+		if found {
+			return dbx.ErrConflict
+		}
+	}
+	return dbx.ErrNotFound
+}
+
+// assignKeys determines whether an entity is new, which is the case when
+// any of its primary keys are zero, and if so generates values for its keys.
+// Keys are only generated when every key assigned by the client is zero; if
+// only some of them are, the zero values are presumed to be intended and the
+// entity is not known to be new. Keys which are generated by the database are
+// left as they are; they are assigned when the entity is inserted.
+func (p *persister) assignKeys(ent interface{}) (bool, error) {
+	keys, err := p.fm.Keys(ent)
+	if err != nil {
+		return false, err
+	}
+	if len(keys.Vals) < 1 {
+		return false, dbx.ErrInvalidKeyCount
+	}
+
+	gen := make(map[string]struct{})
+	for _, e := range p.fm.GeneratedForType(reflect.TypeOf(ent)) {
+		gen[e] = struct{}{}
+	}
+
+	var insert bool
+	var client, zero []reflect.Value
+	for i, e := range keys.Vals {
+		if !e.IsValid() {
+			return false, dbx.ErrInvalidField
+		}
+		if _, ok := gen[keys.Cols[i]]; ok {
+			if e.IsZero() {
+				insert = true
+			}
+			continue
+		}
+		client = append(client, e)
+		if e.IsZero() {
+			zero = append(zero, e)
+		}
+	}
+	if len(zero) == 0 || len(zero) < len(client) {
+		return insert, nil
+	}
+
+	if p.ids != nil {
+		for _, e := range zero {
+			v := p.ids() // generate primary key
+			if !v.IsValid() || !v.Type().AssignableTo(e.Type()) {
+				return false, dbx.ErrInvalidField
+			}
+			e.Set(v)
+		}
+	}
+
+	return true, nil
+}
+
+// Delete deletes an entity. If the entity declares soft delete columns, it
+// is soft deleted instead; see HardDelete.
 func (p *persister) Delete(table string, ent interface{}) error {
+	table, err := tableFor(table, reflect.TypeOf(ent))
+	if err != nil {
+		return err
+	}
+	err = p.beforeDelete(ent)
+	if err != nil {
+		return err
+	}
+	if p.softDeletable(reflect.TypeOf(ent)) {
+		err = p.softDelete(table, ent)
+	} else {
+		err = p.hardDelete(table, ent)
+	}
+	if err != nil {
+		return err
+	}
+	return p.afterDelete(ent)
+}
+
+// HardDelete deletes the row an entity is mapped to, regardless of whether
+// the entity supports soft deletion or not. Related entities are deleted if
+// the persister is so configured.
+func (p *persister) HardDelete(table string, ent interface{}) error {
+	table, err := tableFor(table, reflect.TypeOf(ent))
+	if err != nil {
+		return err
+	}
+	err = p.beforeDelete(ent)
+	if err != nil {
+		return err
+	}
+	err = p.hardDelete(table, ent)
+	if err != nil {
+		return err
+	}
+	return p.afterDelete(ent)
+}
+
+func (p *persister) hardDelete(table string, ent interface{}) error {
 	keys, _ := p.fm.Columns(ent)
-	if len(keys.Cols) != 1 {
+	if len(keys.Cols) < 1 {
 		return dbx.ErrInvalidKeyCount
 	}
 
+	tcols := p.tenantColumns(reflect.TypeOf(ent))
 	if p.conf.DeleteRelated {
+		if tcols != nil { // don't cascade into another tenant's entities
+			found, err := p.exists(table, keys, entity.WithWhere(tcols))
+			if err != nil {
+				return err
+			}
+			if !found {
+				return p.crossTenant(table, keys, tcols)
+			}
+		}
 		if pst, ok := p.reg.GetFor(ent); ok {
 			if c, ok := pst.(DeleteReferencesPersister); ok {
 				err := c.DeleteReferences(p, ent)
@@ -362,29 +702,48 @@ func (p *persister) Delete(table string, ent interface{}) error {
 		}
 	}
 
-	sql, args := p.gen.Delete(table, keys)
-	_, err := p.Context.Exec(sql, args...)
+	err := p.delete(table, keys, tcols)
 	if err != nil {
-		return errors.NewWithSQL(err, sql)
+		return err
 	}
 
+	p.fm.Forget(ent)
 	return nil
 }
 
 func (p *persister) DeleteWithID(table string, typ reflect.Type, id interface{}) error {
-	keys := p.fm.KeysForType(typ)
-	if len(keys) != 1 {
-		return dbx.ErrInvalidKeyCount
+	table, err := tableFor(table, typ)
+	if err != nil {
+		return err
+	}
+	keys, err := p.fm.KeyColumns(typ, id)
+	if err != nil {
+		return err
 	}
 
-	sql, args := p.gen.Delete(table, &entity.Columns{
-		Cols: keys,
-		Vals: []interface{}{id},
-	})
-	_, err := p.Context.Exec(sql, args...)
+	if p.softDeletable(typ) {
+		now := dbx.Now()
+		return p.markDeleted(table, typ, keys, &now)
+	}
+	return p.delete(table, keys, p.tenantColumns(typ))
+}
+
+func (p *persister) delete(table string, keys, tcols *entity.Columns) error {
+	sql, args := p.gen.Delete(table, keys, entity.WithWhere(tcols))
+	res, err := p.Context.Exec(sql, args...)
 	if err != nil {
 		return errors.NewWithSQL(err, sql)
 	}
 
+	if tcols != nil {
+		n, err := res.RowsAffected()
+		if err != nil {
+			return errors.NewWithSQL(err, sql)
+		}
+		if n == 0 {
+			return p.crossTenant(table, keys, tcols)
+		}
+	}
+
 	return nil
 }
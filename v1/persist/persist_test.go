@@ -1,9 +1,14 @@
 package persist
 
 import (
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"math/big"
+	"net/netip"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -29,6 +34,16 @@ const (
 	secondTable = "second_entity"
 	thirdTable  = "third_entity"
 	fourthTable = "fourth_entity"
+	tenantTable = "tenant_entity"
+	compTable   = "composite_entity"
+	versTable   = "versioned_entity"
+	softTable   = "soft_entity"
+	stampTable  = "stamped_entity"
+	retTable    = "returning_entity"
+	serialTable = "serial_entity"
+	jsonTable   = "json_entity"
+	arrayTable  = "array_entity"
+	cryptTable  = "encrypted_entity"
 )
 
 type DontUseThisTestEntity struct {
@@ -413,6 +428,193 @@ func TestPersistOmitEmpty(t *testing.T) {
 
 }
 
+// A context which conceals the capabilities of the context it wraps
+type plainContext struct {
+	dbx.Context
+}
+
+func TestPersistStoreMany(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32))
+	var err error
+
+	tests := []struct {
+		Name    string
+		Context func(func(dbx.Context) error) error
+	}{
+		{
+			"copy", // an implicit transaction is used
+			func(f func(dbx.Context) error) error {
+				return f(db)
+			},
+		},
+		{
+			"copy in transaction",
+			func(f func(dbx.Context) error) error {
+				return db.Transaction(dbx.TransactionHandler(f))
+			},
+		},
+		{
+			"insert", // the context cannot copy
+			func(f func(dbx.Context) error) error {
+				return f(plainContext{db})
+			},
+		},
+	}
+
+	for i, e := range tests {
+		ents := make([]*thirdEntity, 100)
+		for j := range ents {
+			ents[j] = &thirdEntity{A: e.Name, B: j, G: dbx.Now()}
+		}
+		err = e.Context(func(cxt dbx.Context) error {
+			return pst.WithContext(cxt).StoreMany(thirdTable, ents)
+		})
+		if !assert.Nil(t, err, fmt.Sprint(err)) {
+			continue
+		}
+
+		var stored []*thirdEntity
+		err = pst.Select(&stored, `SELECT {*} FROM `+thirdTable+` WHERE a = $1 ORDER BY b`, e.Name)
+		if assert.Nil(t, err, fmt.Sprint(err)) && assert.Len(t, stored, len(ents), "#%d", i) {
+			for j, x := range ents {
+				assert.Len(t, x.Z, 32)
+				assert.Equal(t, x, stored[j])
+			}
+		}
+	}
+
+	err = pst.StoreMany(thirdTable, []thirdEntity{})
+	assert.Nil(t, err, fmt.Sprint(err))
+	err = pst.StoreMany(thirdTable, &thirdEntity{})
+	assert.Equal(t, dbx.ErrInvalidField, err)
+}
+
+func TestPersistStoreManyUpsert(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32)).WithOptions(Upsert())
+	var err error
+
+	ents := make([]*thirdEntity, 10)
+	for i := range ents {
+		ents[i] = &thirdEntity{A: "upsert many", B: i}
+	}
+
+	err = pst.StoreMany(thirdTable, ents)
+	if !assert.Nil(t, err, fmt.Sprint(err)) {
+		return
+	}
+
+	for _, e := range ents {
+		assert.Len(t, e.Z, 32)
+		e.B += 100
+	}
+	ents = append(ents, &thirdEntity{A: "upsert many", B: 200})
+
+	err = pst.StoreMany(thirdTable, ents)
+	if !assert.Nil(t, err, fmt.Sprint(err)) {
+		return
+	}
+
+	var stored []*thirdEntity
+	err = pst.Select(&stored, `SELECT {*} FROM `+thirdTable+` WHERE a = $1 ORDER BY b`, "upsert many")
+	if assert.Nil(t, err, fmt.Sprint(err)) && assert.Len(t, stored, len(ents)) {
+		for i, e := range ents {
+			assert.Equal(t, e, stored[i])
+		}
+	}
+
+	tenantA := pst.WithOptions(Tenant("tenant_a"))
+	tenantB := pst.WithOptions(Tenant("tenant_b"))
+
+	tents := []*tenantEntity{{B: "First"}, {B: "Second"}}
+	err = tenantA.StoreMany(tenantTable, tents)
+	assert.Nil(t, err, fmt.Sprint(err))
+
+	err = tenantB.StoreMany(tenantTable, []*tenantEntity{{B: "Innocent"}, {A: tents[0].A, B: "Hijacked"}})
+	assert.Equal(t, dbx.ErrCrossTenant, err)
+
+	count, err := pst.Count(`SELECT COUNT(*) FROM `+tenantTable+` WHERE b = $1`, "Innocent")
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, 0, count) // the whole batch was rolled back
+	}
+}
+
+type compositeEntity struct {
+	A string `db:"a,pk"`
+	B string `db:"b,pk"`
+	C int    `db:"c"`
+}
+
+type positionEntity struct {
+	A string `db:"a,pk"`
+	C int    `db:"c,pk"`
+}
+
+func TestPersistCompositeKeys(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32))
+	typ := reflect.TypeOf((*compositeEntity)(nil))
+	var err error
+
+	e1 := &compositeEntity{A: "AAA", B: "BBB", C: 1}
+	err = pst.Store(compTable, e1, nil) // inserted, since it doesn't exist
+	assert.Nil(t, err, fmt.Sprint(err))
+
+	e1.C = 2
+	err = pst.Store(compTable, e1, nil) // updated, since it does
+	assert.Nil(t, err, fmt.Sprint(err))
+
+	e2 := &compositeEntity{C: 3}
+	err = pst.Store(compTable, e2, nil) // the zero keys are generated
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Len(t, e2.A, 32)
+		assert.Len(t, e2.B, 32)
+	}
+
+	e3 := &compositeEntity{A: "AAA", C: 4}
+	err = pst.Store(compTable, e3, nil) // the zero key is intended, not generated
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, "", e3.B)
+	}
+	e3.C = 5
+	err = pst.Store(compTable, e3, nil) // updated, since it exists
+	assert.Nil(t, err, fmt.Sprint(err))
+
+	err = pst.Store(compTable, &positionEntity{}, nil) // an id can't be assigned to an int key
+	assert.Equal(t, dbx.ErrInvalidField, err)
+
+	for _, id := range []interface{}{
+		[]string{"AAA", "BBB"},
+		map[string]interface{}{"a": "AAA", "b": "BBB"},
+		&compositeEntity{A: "AAA", B: "BBB"},
+	} {
+		var c1 compositeEntity
+		err = pst.Fetch(compTable, &c1, id)
+		if assert.Nil(t, err, fmt.Sprint(err)) {
+			assert.Equal(t, e1, &c1)
+		}
+	}
+
+	var c2 compositeEntity
+	err = pst.Fetch(compTable, &c2, []string{"AAA", "NOT A VALID KEY"})
+	assert.Equal(t, dbx.ErrNotFound, err)
+	err = pst.Fetch(compTable, &c2, "AAA")
+	assert.Equal(t, dbx.ErrInvalidField, err)
+
+	err = pst.Delete(compTable, e1)
+	assert.Nil(t, err, fmt.Sprint(err))
+	err = pst.DeleteWithID(compTable, typ, []string{e2.A, e2.B})
+	assert.Nil(t, err, fmt.Sprint(err))
+	err = pst.DeleteWithID(compTable, typ, []string{e3.A, e3.B})
+	assert.Nil(t, err, fmt.Sprint(err))
+
+	count, err := pst.Count(`SELECT COUNT(*) FROM ` + compTable)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, 0, count)
+	}
+}
+
 func TestPersistFetchAndStoreInATightLoop(t *testing.T) {
 	db := test.DB()
 	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32))
@@ -434,6 +636,55 @@ func TestPersistFetchAndStoreInATightLoop(t *testing.T) {
 
 }
 
+func TestPersistEach(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32))
+	var err error
+
+	for i := 0; i < 25; i++ {
+		_, err = pst.Exec(`INSERT INTO `+fourthTable+` (x, z) VALUES ($1, $2) ON CONFLICT (x) DO UPDATE SET z = $2`, fmt.Sprintf("each_%02d", i), i)
+		assert.Nil(t, err, fmt.Sprint(err))
+	}
+
+	const query = `SELECT {*} FROM ` + fourthTable + ` WHERE x LIKE 'each_%' ORDER BY z`
+	typ := reflect.TypeOf((*secondEntity)(nil))
+
+	var n int
+	err = pst.Each(typ, func(ent interface{}) error {
+		e := ent.(*secondEntity)
+		assert.Equal(t, fmt.Sprintf("each_%02d", n), e.X)
+		assert.Equal(t, n, e.Z)
+		n++
+		return nil
+	}, query)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, 25, n)
+	}
+
+	n = 0
+	err = pst.Each(typ, func(ent interface{}) error {
+		if n++; n == 3 {
+			return dbx.ErrStopIteration
+		}
+		return nil
+	}, query)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, 3, n)
+	}
+
+	err = db.Transaction(func(cxt dbx.Context) error {
+		n = 0
+		return pst.WithContext(cxt).WithOptions(Cursor(10)).Each(typ, func(ent interface{}) error {
+			assert.Equal(t, n, ent.(*secondEntity).Z)
+			n++
+			return nil
+		}, query)
+	})
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, 25, n)
+	}
+}
+
 func TestInvalidParamInSelectOneDoesntLeakConns(t *testing.T) {
 	db := test.DB()
 	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32))
@@ -447,3 +698,900 @@ func TestInvalidParamInSelectOneDoesntLeakConns(t *testing.T) {
 
 	// if we don't hang forever, we have succeeded
 }
+
+type tenantEntity struct {
+	A string `db:"a,pk"`
+	T string `db:"t,tenant"`
+	B string `db:"b"`
+}
+
+func TestPersistTenant(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32))
+	var err error
+
+	var (
+		tenantA = pst.WithOptions(Tenant("tenant_a"))
+		tenantB = pst.WithOptions(Tenant("tenant_b"))
+	)
+
+	e1 := &tenantEntity{B: "Belongs to A"}
+	err = tenantA.Store(tenantTable, e1, nil)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Len(t, e1.A, 32)
+		assert.Equal(t, "tenant_a", e1.T)
+	}
+
+	var c1 tenantEntity
+	err = tenantA.Fetch(tenantTable, &c1, e1.A)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, e1, &c1)
+	}
+
+	var c2 tenantEntity
+	err = tenantB.Fetch(tenantTable, &c2, e1.A)
+	assert.Equal(t, dbx.ErrCrossTenant, err)
+	err = tenantB.Fetch(tenantTable, &c2, "THIS IS NOT A VALID IDENT, BRAH")
+	assert.Equal(t, dbx.ErrNotFound, err)
+
+	// an entity that already belongs to another tenant cannot be stored
+	err = tenantB.Store(tenantTable, e1, nil)
+	assert.Equal(t, dbx.ErrCrossTenant, err)
+
+	// an entity that doesn't specify its tenant is scoped on update
+	e2 := &tenantEntity{A: e1.A, B: "Hijacked by B"}
+	err = tenantB.Store(tenantTable, e2, nil)
+	assert.Equal(t, dbx.ErrCrossTenant, err)
+
+	err = tenantB.Delete(tenantTable, e1)
+	assert.Equal(t, dbx.ErrCrossTenant, err)
+	err = tenantB.DeleteWithID(tenantTable, reflect.TypeOf(e1), e1.A)
+	assert.Equal(t, dbx.ErrCrossTenant, err)
+
+	var c3 tenantEntity
+	err = pst.Fetch(tenantTable, &c3, e1.A)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, "Belongs to A", c3.B)
+	}
+
+	err = tenantA.Delete(tenantTable, e1)
+	assert.Nil(t, err, fmt.Sprint(err))
+	err = tenantA.Fetch(tenantTable, &c1, e1.A)
+	assert.Equal(t, dbx.ErrNotFound, err)
+}
+
+type trackedEntity struct {
+	entity.Tracked
+	A string `db:"a,pk"`
+	B string `db:"b"`
+	C int    `db:"c"`
+}
+
+func TestPersistDirtyTracking(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32))
+	var err error
+
+	e1 := &trackedEntity{B: "Original", C: 1}
+	err = pst.Store(firstTable, e1, nil)
+	if !assert.Nil(t, err, fmt.Sprint(err)) {
+		return
+	}
+	dirty, ok := pst.FieldMapper().Dirty(e1)
+	if assert.True(t, ok) {
+		assert.Len(t, dirty, 0)
+	}
+
+	// modify a column behind the entity's back; since it hasn't been
+	// changed on the entity it must not be overwritten below
+	_, err = db.Exec(`UPDATE `+firstTable+` SET b = $1 WHERE a = $2`, "Concurrent", e1.A)
+	if !assert.Nil(t, err, fmt.Sprint(err)) {
+		return
+	}
+
+	e1.C = 2
+	err = pst.Store(firstTable, e1, nil)
+	assert.Nil(t, err, fmt.Sprint(err))
+
+	var c1 trackedEntity
+	err = pst.Fetch(firstTable, &c1, e1.A)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, "Concurrent", c1.B)
+		assert.Equal(t, 2, c1.C)
+		dirty, ok = pst.FieldMapper().Dirty(&c1)
+		if assert.True(t, ok) {
+			assert.Len(t, dirty, 0)
+		}
+	}
+
+	err = pst.Update(firstTable, e1, nil) // nothing has changed, so nothing is written
+	assert.Nil(t, err, fmt.Sprint(err))
+
+	err = pst.Delete(firstTable, &c1)
+	assert.Nil(t, err, fmt.Sprint(err))
+	_, ok = pst.FieldMapper().Dirty(&c1)
+	assert.False(t, ok)
+
+	err = pst.Update(firstTable, e1, nil) // ...but the row must still exist
+	assert.Equal(t, dbx.ErrNotFound, err)
+}
+
+type versionedEntity struct {
+	A string `db:"a,pk"`
+	B string `db:"b"`
+	V int    `db:"v,version"`
+}
+
+func TestPersistVersion(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32))
+	var err error
+
+	e1 := &versionedEntity{B: "First"}
+	err = pst.Store(versTable, e1, nil)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, 1, e1.V) // initialized on insert
+	}
+
+	var c1, c2 versionedEntity
+	err = pst.Fetch(versTable, &c1, e1.A)
+	assert.Nil(t, err, fmt.Sprint(err))
+	err = pst.Fetch(versTable, &c2, e1.A)
+	assert.Nil(t, err, fmt.Sprint(err))
+
+	c1.B = "Second"
+	err = pst.Store(versTable, &c1, nil)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, 2, c1.V)
+	}
+
+	c2.B = "Lost"
+	err = pst.Store(versTable, &c2, nil) // c2 is stale
+	assert.Equal(t, dbx.ErrConflict, err)
+	assert.Equal(t, 1, c2.V)
+
+	var c3 versionedEntity
+	err = pst.Fetch(versTable, &c3, e1.A)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, &c1, &c3)
+	}
+
+	err = pst.Delete(versTable, &c3)
+	assert.Nil(t, err, fmt.Sprint(err))
+	err = pst.Store(versTable, &c1, nil) // the row no longer exists
+	assert.Equal(t, dbx.ErrNotFound, err)
+}
+
+type softEntity struct {
+	A string     `db:"a,pk"`
+	B string     `db:"b"`
+	D *time.Time `db:"d,deleted"`
+}
+
+func TestPersistSoftDelete(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32))
+	typ := reflect.TypeOf((*softEntity)(nil))
+	const query = `SELECT {*} FROM ` + softTable + ` WHERE b = 'soft' AND {@live} ORDER BY a`
+	var err error
+
+	e1, e2 := &softEntity{B: "soft"}, &softEntity{B: "soft"}
+	for _, e := range []*softEntity{e1, e2} {
+		err = pst.Store(softTable, e, nil)
+		assert.Nil(t, err, fmt.Sprint(err))
+	}
+
+	err = pst.Delete(softTable, e1)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.NotNil(t, e1.D)
+	}
+	err = pst.DeleteWithID(softTable, typ, e2.A)
+	assert.Nil(t, err, fmt.Sprint(err))
+
+	var c1 softEntity
+	err = pst.Fetch(softTable, &c1, e1.A)
+	assert.Equal(t, dbx.ErrNotFound, err)
+
+	var live []*softEntity
+	err = pst.Select(&live, query)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Len(t, live, 0)
+	}
+
+	count, err := pst.Count(`SELECT COUNT(*) FROM ` + softTable + ` WHERE b = 'soft'`)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, 2, count) // the rows still exist
+	}
+
+	err = pst.Restore(softTable, e1)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Nil(t, e1.D)
+	}
+	err = pst.Fetch(softTable, &c1, e1.A)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, e1, &c1)
+	}
+	err = pst.Select(&live, query)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, []*softEntity{e1}, live)
+	}
+
+	err = pst.HardDelete(softTable, e1)
+	assert.Nil(t, err, fmt.Sprint(err))
+	err = pst.HardDelete(softTable, e2)
+	assert.Nil(t, err, fmt.Sprint(err))
+
+	count, err = pst.Count(`SELECT COUNT(*) FROM ` + softTable + ` WHERE b = 'soft'`)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, 0, count)
+	}
+
+	err = pst.Restore(firstTable, &trackedEntity{A: "AAA"})
+	assert.Equal(t, dbx.ErrInvalidField, err)
+
+	err = pst.Store(softTable, &struct { // a zero time would hide the row
+		A string    `db:"a,pk"`
+		D time.Time `db:"d,deleted"`
+	}{}, nil)
+	assert.Equal(t, dbx.ErrInvalidField, err)
+}
+
+type stampedEntity struct {
+	A string    `db:"a,pk"`
+	B string    `db:"b"`
+	C time.Time `db:"c,created"`
+	U time.Time `db:"u,updated"`
+}
+
+func TestPersistTimestamps(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32))
+	var err error
+
+	e1 := &stampedEntity{B: "First"}
+	err = pst.Store(stampTable, e1, nil)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.False(t, e1.C.IsZero())
+		assert.Equal(t, e1.C, e1.U)
+	}
+	created := e1.C
+
+	for _, cols := range [][]string{nil, {"b"}} {
+		time.Sleep(time.Millisecond * 2)
+		prev := e1.U
+		e1.B = "Updated"
+		err = pst.Store(stampTable, e1, cols)
+		if assert.Nil(t, err, fmt.Sprint(err)) {
+			assert.Equal(t, created, e1.C)
+			assert.True(t, e1.U.After(prev))
+		}
+		var c1 stampedEntity
+		err = pst.Fetch(stampTable, &c1, e1.A)
+		if assert.Nil(t, err, fmt.Sprint(err)) {
+			assert.True(t, created.Equal(c1.C))
+			assert.True(t, e1.U.Equal(c1.U))
+		}
+	}
+
+	// an upsert of an existing row must not replace its creation time
+	time.Sleep(time.Millisecond * 2)
+	e2 := &stampedEntity{A: e1.A, B: "Upserted"}
+	err = pst.WithOptions(Upsert()).Store(stampTable, e2, nil)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.True(t, created.Equal(e2.C), "The existing creation time is read back")
+	}
+	var c2 stampedEntity
+	err = pst.Fetch(stampTable, &c2, e1.A)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.True(t, created.Equal(c2.C))
+		assert.True(t, e2.U.Equal(c2.U))
+	}
+
+	err = pst.Delete(stampTable, e1)
+	assert.Nil(t, err, fmt.Sprint(err))
+}
+
+type returningEntity struct {
+	A string    `db:"a,pk"`
+	B string    `db:"b"`
+	C time.Time `db:"c,generated"`
+	N int       `db:"n,generated"`
+	U string    `db:"u,generated"`
+}
+
+func TestPersistReturning(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32))
+	var err error
+
+	e1 := &returningEntity{B: "hello"}
+	err = pst.Store(retTable, e1, nil)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.False(t, e1.C.IsZero())
+		assert.NotEqual(t, 0, e1.N)
+		assert.Equal(t, "HELLO", e1.U)
+	}
+	created, serial := e1.C, e1.N
+
+	e1.B = "goodbye"
+	err = pst.Store(retTable, e1, nil)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.True(t, created.Equal(e1.C))
+		assert.Equal(t, serial, e1.N)
+		assert.Equal(t, "GOODBYE", e1.U)
+	}
+
+	var c1 returningEntity
+	err = pst.Fetch(retTable, &c1, e1.A)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.True(t, e1.C.Equal(c1.C))
+		assert.Equal(t, e1.N, c1.N)
+		assert.Equal(t, e1.U, c1.U)
+	}
+
+	err = pst.Delete(retTable, e1)
+	assert.Nil(t, err, fmt.Sprint(err))
+
+	// values which were read back are not written when the entity is inserted
+	// again, since the database will not accept them for generated columns
+	err = pst.Insert(retTable, e1)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, "GOODBYE", e1.U)
+	}
+	err = pst.Delete(retTable, e1)
+	assert.Nil(t, err, fmt.Sprint(err))
+}
+
+type serialEntity struct {
+	ID int64  `db:"id,pk,generated"`
+	B  string `db:"b"`
+}
+
+func TestPersistGeneratedKeys(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32))
+	var err error
+
+	e1 := &serialEntity{B: "First"}
+	err = pst.Store(serialTable, e1, nil) // the key is assigned by the database
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.NotEqual(t, int64(0), e1.ID)
+	}
+	id := e1.ID
+
+	e1.B = "Updated"
+	err = pst.Store(serialTable, e1, nil)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, id, e1.ID)
+	}
+
+	var c1 serialEntity
+	err = pst.Fetch(serialTable, &c1, id)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, e1, &c1)
+	}
+
+	ents := []*serialEntity{{B: "Second"}, {B: "Third"}}
+	err = pst.StoreMany(serialTable, ents)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		for _, e := range ents {
+			assert.Greater(t, e.ID, id)
+			var c serialEntity
+			err = pst.Fetch(serialTable, &c, e.ID)
+			if assert.Nil(t, err, fmt.Sprint(err)) {
+				assert.Equal(t, e, &c)
+			}
+		}
+		assert.NotEqual(t, ents[0].ID, ents[1].ID)
+	}
+
+	for _, e := range append(ents, e1) {
+		err = pst.Delete(serialTable, e)
+		assert.Nil(t, err, fmt.Sprint(err))
+	}
+}
+
+type newerEntity struct {
+	A     string `db:"a,pk"`
+	C     int    `db:"c"`
+	isNew bool
+}
+
+func (e *newerEntity) IsNew() bool {
+	return e.isNew
+}
+
+func TestPersistInsertUpdate(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32))
+	var err error
+
+	e1 := &secondEntity{X: "insert_update", Z: 1}
+	err = pst.Update(fourthTable, e1, nil) // doesn't exist yet
+	assert.Equal(t, dbx.ErrNotFound, err)
+	err = pst.Store(fourthTable, e1, nil) // neither does this, the key is client-assigned
+	assert.Equal(t, dbx.ErrNotFound, err)
+
+	err = pst.Insert(fourthTable, e1)
+	assert.Nil(t, err, fmt.Sprint(err))
+	err = pst.Insert(fourthTable, e1) // already exists
+	assert.NotNil(t, err)
+
+	e1.Z = 2
+	err = pst.Update(fourthTable, e1, nil)
+	assert.Nil(t, err, fmt.Sprint(err))
+
+	var c1 secondEntity
+	err = pst.Fetch(fourthTable, &c1, e1.X)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, e1, &c1)
+	}
+
+	// the entity decides whether it's inserted or updated
+	e2 := &newerEntity{A: "insert_update", C: 1, isNew: true}
+	err = pst.Store(firstTable, e2, nil)
+	assert.Nil(t, err, fmt.Sprint(err))
+	e2.C, e2.isNew = 2, false
+	err = pst.Store(firstTable, e2, nil)
+	assert.Nil(t, err, fmt.Sprint(err))
+
+	var c2 newerEntity
+	err = pst.Fetch(firstTable, &c2, e2.A)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, 2, c2.C)
+	}
+
+	err = pst.Delete(fourthTable, e1)
+	assert.Nil(t, err, fmt.Sprint(err))
+	err = pst.Delete(firstTable, e2)
+	assert.Nil(t, err, fmt.Sprint(err))
+}
+
+func TestPersistUpsertOptions(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32))
+	var err error
+
+	incr := pst.WithOptions(Upsert(entity.WithMerge("c", entity.MergeIncrement)))
+	for i := 0; i < 3; i++ {
+		err = incr.Store(compTable, &compositeEntity{A: "upsert", B: "options", C: 1}, nil)
+		assert.Nil(t, err, fmt.Sprint(err))
+	}
+
+	var c1 compositeEntity
+	err = pst.Fetch(compTable, &c1, []string{"upsert", "options"})
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, 3, c1.C)
+	}
+
+	noop := pst.WithOptions(Upsert(entity.WithDoNothing()))
+	err = noop.Store(compTable, &compositeEntity{A: "upsert", B: "options", C: 100}, nil)
+	assert.Nil(t, err, fmt.Sprint(err))
+	err = noop.StoreMany(compTable, []*compositeEntity{{A: "upsert", B: "options", C: 100}})
+	assert.Nil(t, err, fmt.Sprint(err))
+
+	err = pst.Fetch(compTable, &c1, []string{"upsert", "options"})
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, 3, c1.C)
+	}
+
+	err = pst.Delete(compTable, &c1)
+	assert.Nil(t, err, fmt.Sprint(err))
+
+	// when every column is kept there is nothing to update, so conflicting
+	// rows are left alone without being mistaken for another tenant's
+	keep := pst.WithOptions(Tenant("tenant_keep"), Upsert(entity.WithMerge("b", entity.MergeKeep), entity.WithMerge("t", entity.MergeKeep)))
+	tents := []*tenantEntity{{B: "Kept"}}
+	err = keep.StoreMany(tenantTable, tents)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		err = keep.StoreMany(tenantTable, []*tenantEntity{{A: tents[0].A, B: "Replaced"}})
+		assert.Nil(t, err, fmt.Sprint(err))
+	}
+	var c2 tenantEntity
+	err = keep.Fetch(tenantTable, &c2, tents[0].A)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, "Kept", c2.B)
+	}
+}
+
+type hookedEntity struct {
+	A       string `db:"a,pk"`
+	B       string `db:"b"`
+	C       int    `db:"c"`
+	Derived string `db:"-"`
+	Locked  bool   `db:"-"`
+	events  []string
+}
+
+func (e *hookedEntity) BeforeStore(pst Persister) error {
+	if e.C < 0 {
+		return errors.New("C must not be negative")
+	}
+	e.B = strings.TrimSpace(e.B)
+	e.events = append(e.events, "before store")
+	return nil
+}
+
+func (e *hookedEntity) AfterStore(pst Persister) error {
+	e.events = append(e.events, "after store")
+	return nil
+}
+
+func (e *hookedEntity) AfterFetch(pst Persister) error {
+	e.Derived = strings.ToUpper(e.B)
+	return nil
+}
+
+func (e *hookedEntity) BeforeDelete(pst Persister) error {
+	if e.Locked {
+		return errors.New("Entity is locked")
+	}
+	e.events = append(e.events, "before delete")
+	return nil
+}
+
+func (e *hookedEntity) AfterDelete(pst Persister) error {
+	// the row is gone by the time this is invoked
+	n, err := pst.Count(`SELECT COUNT(*) FROM `+firstTable+` WHERE a = $1`, e.A)
+	if err != nil {
+		return err
+	}
+	e.events = append(e.events, fmt.Sprintf("after delete: %d", n))
+	return nil
+}
+
+func TestPersistHooks(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32))
+	var err error
+
+	err = pst.Store(firstTable, &hookedEntity{B: "Invalid", C: -1}, nil)
+	assert.EqualError(t, err, "C must not be negative")
+
+	e1 := &hookedEntity{B: "  hooked  ", C: 1}
+	err = pst.Store(firstTable, e1, nil)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, "hooked", e1.B)
+		assert.Equal(t, []string{"before store", "after store"}, e1.events)
+	}
+
+	var c1 hookedEntity
+	err = pst.Fetch(firstTable, &c1, e1.A)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, "HOOKED", c1.Derived)
+	}
+
+	var s1 []*hookedEntity
+	err = pst.Select(&s1, `SELECT {*} FROM `+firstTable+` WHERE a = $1`, e1.A)
+	if assert.Nil(t, err, fmt.Sprint(err)) && assert.Len(t, s1, 1) {
+		assert.Equal(t, "HOOKED", s1[0].Derived)
+	}
+
+	c1.Locked = true
+	err = pst.Delete(firstTable, &c1)
+	assert.EqualError(t, err, "Entity is locked")
+	err = pst.Fetch(firstTable, &c1, e1.A) // still exists
+	assert.Nil(t, err, fmt.Sprint(err))
+
+	e1.events = nil
+	err = pst.Delete(firstTable, e1)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, []string{"before delete", "after delete: 0"}, e1.events)
+	}
+}
+
+type checkedEntity struct {
+	A string `db:"a,pk" check:"required"`
+	B string `db:"b" check:"required;max=8"`
+	C int    `db:"c" check:"enum=1|2"`
+}
+
+func TestPersistValidation(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32))
+	var err error
+	var verr *entity.ValidationError
+
+	e1 := &checkedEntity{C: 3}
+	err = pst.Store(firstTable, e1, nil)
+	if assert.ErrorAs(t, err, &verr) {
+		assert.Equal(t, []entity.FieldError{
+			{Path: "b", Rule: "required", Message: "is required"},
+			{Path: "c", Rule: "enum", Message: "is not an allowed value"},
+		}, verr.Fields)
+	}
+	assert.Len(t, e1.A, 32, "Keys are assigned before entities are validated")
+
+	err = pst.StoreMany(firstTable, []*checkedEntity{{B: "Valid", C: 1}, {B: "Much too long", C: 1}})
+	assert.ErrorAs(t, err, &verr)
+
+	e2 := &checkedEntity{B: "Valid", C: 1}
+	err = pst.Store(firstTable, e2, nil)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		e2.B = "Much too long"
+		err = pst.Update(firstTable, e2, nil)
+		assert.ErrorAs(t, err, &verr)
+
+		var c2 checkedEntity
+		err = pst.Fetch(firstTable, &c2, e2.A)
+		if assert.Nil(t, err, fmt.Sprint(err)) {
+			assert.Equal(t, "Valid", c2.B)
+		}
+	}
+}
+
+type convertedEntity struct {
+	A string     `db:"a,pk"`
+	B netip.Addr `db:"b"`
+	C *big.Int   `db:"c"`
+}
+
+func TestPersistConverters(t *testing.T) {
+	db := test.DB()
+	fm := entity.NewFieldMapper(
+		entity.WithConverter(
+			func(v netip.Addr) (driver.Value, error) { return v.String(), nil },
+			func(src interface{}) (netip.Addr, error) { return netip.ParseAddr(fmt.Sprintf("%s", src)) },
+		),
+		entity.WithConverter(
+			func(v big.Int) (driver.Value, error) { return v.Int64(), nil },
+			func(src interface{}) (big.Int, error) { return *big.NewInt(src.(int64)), nil },
+		),
+	)
+	pst := New(db, fm, registry.New(), ident.AlphaNumeric(32))
+	var err error
+
+	e1 := &convertedEntity{B: netip.MustParseAddr("10.0.0.1"), C: big.NewInt(99)}
+	err = pst.Store(firstTable, e1, nil)
+	if !assert.Nil(t, err, fmt.Sprint(err)) {
+		return
+	}
+
+	var b string
+	var c int64
+	err = db.QueryRow(`SELECT b, c FROM `+firstTable+` WHERE a = $1`, e1.A).Scan(&b, &c)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, "10.0.0.1", b)
+		assert.Equal(t, int64(99), c)
+	}
+
+	var c1 convertedEntity
+	err = pst.Fetch(firstTable, &c1, e1.A)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, e1, &c1)
+	}
+
+	e1.C = nil
+	err = pst.Store(firstTable, e1, nil)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		var s1 []*convertedEntity
+		err = pst.Select(&s1, `SELECT {*} FROM `+firstTable+` WHERE a = $1`, e1.A)
+		if assert.Nil(t, err, fmt.Sprint(err)) && assert.Len(t, s1, 1) {
+			assert.Equal(t, e1, s1[0])
+		}
+	}
+}
+
+type jsonDocument struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+type jsonEntity struct {
+	A string                 `db:"a,pk"`
+	B map[string]interface{} `db:"b,json"`
+	C *jsonDocument          `db:"c,json"`
+}
+
+func TestPersistJSON(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32))
+	var err error
+
+	e1 := &jsonEntity{
+		B: map[string]interface{}{"owner": map[string]interface{}{"name": "Bob"}, "count": float64(3)},
+		C: &jsonDocument{Name: "First", Tags: []string{"a", "b"}},
+	}
+	e2 := &jsonEntity{}
+	for _, e := range []*jsonEntity{e1, e2} {
+		err = pst.Store(jsonTable, e, nil)
+		if !assert.Nil(t, err, fmt.Sprint(err)) {
+			return
+		}
+	}
+
+	var c1, c2 jsonEntity
+	err = pst.Fetch(jsonTable, &c1, e1.A)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, e1, &c1)
+	}
+	err = pst.Fetch(jsonTable, &c2, e2.A)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, e2, &c2, "NULL documents produce zero values")
+	}
+
+	var s1 []*jsonEntity
+	err = pst.Select(&s1, `SELECT {*} FROM `+jsonTable+` WHERE {@jsontext(b, owner, name)} = $1 AND {@jsontext(c, tags, 1)} = $2`, "Bob", "b")
+	if assert.Nil(t, err, fmt.Sprint(err)) && assert.Len(t, s1, 1) {
+		assert.Equal(t, e1, s1[0])
+	}
+}
+
+type arrayEntity struct {
+	A string   `db:"a,pk"`
+	B []string `db:"b,array"`
+	C []int64  `db:"c,array"`
+	D int      `db:"d"`
+}
+
+func TestPersistArrays(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32))
+	var err error
+
+	e1 := &arrayEntity{B: []string{"a", "b,c"}, C: []int64{1, 2, 3}, D: 1}
+	e2 := &arrayEntity{B: []string{}, D: 2}
+	for _, e := range []*arrayEntity{e1, e2} {
+		err = pst.Store(arrayTable, e, nil)
+		if !assert.Nil(t, err, fmt.Sprint(err)) {
+			return
+		}
+	}
+
+	var c1, c2 arrayEntity
+	err = pst.Fetch(arrayTable, &c1, e1.A)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, e1, &c1)
+	}
+	err = pst.Fetch(arrayTable, &c2, e2.A)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, e2, &c2)
+		assert.Nil(t, c2.C, "NULL arrays produce nil slices")
+	}
+
+	var s1 []*arrayEntity
+	err = pst.Select(&s1, `SELECT {*} FROM `+arrayTable+` WHERE {@any(a, $1)} AND $2 = ANY(b) ORDER BY d`, []string{e1.A, e2.A}, "b,c")
+	if assert.Nil(t, err, fmt.Sprint(err)) && assert.Len(t, s1, 1) {
+		assert.Equal(t, e1, s1[0])
+	}
+
+	n, err := pst.Count(`SELECT COUNT(*) FROM `+arrayTable+` WHERE {@any(d, $1)}`, []int{1, 2})
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, 2, n)
+	}
+}
+
+type encryptedEntity struct {
+	A string  `db:"a,pk"`
+	B string  `db:"b,encrypt"`
+	C *string `db:"c,encrypt=deterministic"`
+}
+
+func TestPersistEncryption(t *testing.T) {
+	db := test.DB()
+	keys := entity.StaticKeys{
+		Current: "k1",
+		Keys: map[string][]byte{
+			"k1": []byte("0123456789abcdef0123456789abcdef"),
+			"k2": []byte("fedcba9876543210fedcba9876543210"),
+		},
+	}
+	fm := entity.NewFieldMapper(entity.WithCipher(entity.NewAESCipher(keys)))
+	pst := New(db, fm, registry.New(), ident.AlphaNumeric(32))
+	var err error
+
+	email := "bob@example.com"
+	e1 := &encryptedEntity{B: "Secret", C: &email}
+	e2 := &encryptedEntity{B: "Other"}
+	for _, e := range []*encryptedEntity{e1, e2} {
+		err = pst.Store(cryptTable, e, nil)
+		if !assert.Nil(t, err, fmt.Sprint(err)) {
+			return
+		}
+	}
+
+	var b []byte
+	err = db.QueryRow(`SELECT b FROM `+cryptTable+` WHERE a = $1`, e1.A).Scan(&b)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.False(t, strings.Contains(string(b), "Secret"))
+	}
+
+	var c1, c2 encryptedEntity
+	err = pst.Fetch(cryptTable, &c1, e1.A)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, e1, &c1)
+	}
+	err = pst.Fetch(cryptTable, &c2, e2.A)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, e2, &c2)
+	}
+
+	var s1 []*encryptedEntity
+	err = pst.Select(&s1, `SELECT {*} FROM `+cryptTable+` WHERE c = $1`, fm.EncryptedLookup(email))
+	if assert.Nil(t, err, fmt.Sprint(err)) && assert.Len(t, s1, 1) {
+		assert.Equal(t, e1, s1[0])
+	}
+
+	// rows encrypted with a previous key are still readable after rotation
+	keys.Current = "k2"
+	rot := New(db, entity.NewFieldMapper(entity.WithCipher(entity.NewAESCipher(keys))), registry.New(), ident.AlphaNumeric(32))
+	var r1 encryptedEntity
+	err = rot.Fetch(cryptTable, &r1, e1.A)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, e1, &r1)
+	}
+}
+
+type namedEntity struct {
+	A      string `db:"a,pk"`
+	B      string
+	C      int
+	Ignore string `db:"-"`
+}
+
+func TestPersistNaming(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(entity.WithNaming(entity.SnakeCase)), registry.New(), ident.AlphaNumeric(32))
+	var err error
+
+	e1 := &namedEntity{B: "Untagged", C: 7, Ignore: "Not stored"}
+	err = pst.Store(firstTable, e1, nil)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		var c1 namedEntity
+		err = pst.Fetch(firstTable, &c1, e1.A)
+		if assert.Nil(t, err, fmt.Sprint(err)) {
+			assert.Equal(t, &namedEntity{A: e1.A, B: "Untagged", C: 7}, &c1)
+		}
+	}
+}
+
+type tablerEntity struct {
+	A string `db:"a,pk"`
+	B string `db:"b"`
+}
+
+func (tablerEntity) Table() string { return firstTable }
+
+func TestPersistTabler(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32))
+	var err error
+
+	err = pst.Store("", &namedEntity{B: "No table"}, nil)
+	assert.ErrorIs(t, err, dbx.ErrNoTable)
+
+	e1 := &tablerEntity{B: "Declared"}
+	err = StoreEntity(pst, e1, nil)
+	if !assert.Nil(t, err, fmt.Sprint(err)) {
+		return
+	}
+
+	var c1 tablerEntity
+	err = FetchEntity(pst, &c1, e1.A)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, e1, &c1)
+	}
+
+	var s1 []*tablerEntity
+	err = pst.Select(&s1, `SELECT {*} FROM {@table} WHERE a = $1`, e1.A)
+	if assert.Nil(t, err, fmt.Sprint(err)) && assert.Len(t, s1, 1) {
+		assert.Equal(t, e1, s1[0])
+	}
+
+	repo, err := NewRepository[tablerEntity](pst, "")
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, firstTable, repo.Table())
+	}
+	_, err = NewRepository[namedEntity](pst, "")
+	assert.ErrorIs(t, err, dbx.ErrNoTable)
+
+	var s2 []*namedEntity
+	err = pst.Select(&s2, `SELECT {*} FROM {@table}`)
+	assert.ErrorIs(t, err, dbx.ErrNoTable)
+
+	err = DeleteEntity(pst, e1)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		err = pst.Fetch("", &c1, e1.A)
+		assert.Equal(t, dbx.ErrNotFound, err)
+	}
+}
@@ -7,7 +7,9 @@ import (
 )
 
 type Context struct {
+	Table   string // the table of the entity, referenced by @table
 	Columns []string
+	Deleted []string // soft delete columns, referenced by @live
 	Vars    map[string]interface{}
 }
 
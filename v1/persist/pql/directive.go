@@ -0,0 +1,143 @@
+package pql
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bww/go-dbx/v1"
+)
+
+type directiveFunc func(io.Writer, Context, []string) error
+
+type directiveDef struct {
+	min, max int // the range of arguments accepted; a negative max is unbounded
+	exec     directiveFunc
+}
+
+var directives = map[string]directiveDef{
+	"table":    {0, 0, execTable},
+	"live":     {0, 1, execLive},
+	"json":     {2, -1, execJSON},
+	"jsontext": {2, -1, execJSONText},
+	"any":      {2, 2, execAny},
+}
+
+type directiveNode struct {
+	node
+	name string
+	args []string
+}
+
+func (n directiveNode) Exec(w io.Writer, cxt Context) error {
+	err := directives[n.name].exec(w, cxt, n.args)
+	if err != nil {
+		return newErr(err, n.span)
+	}
+	return nil
+}
+
+// execTable produces the table of the entity a query is compiled for, as
+// declared by the entity. For example, {@table} may produce:
+//
+//	users
+func execTable(w io.Writer, cxt Context, args []string) error {
+	if cxt.Table == "" {
+		return dbx.ErrNoTable
+	}
+	_, err := w.Write([]byte(cxt.Table))
+	return err
+}
+
+// execLive produces a predicate which excludes soft deleted rows, optionally
+// qualified by a table name or alias. For example, {@live(p)} may produce:
+//
+//	p.deleted_at IS NULL
+//
+// If the entity has no soft delete columns the predicate is simply TRUE.
+func execLive(w io.Writer, cxt Context, args []string) error {
+	if len(cxt.Deleted) == 0 {
+		_, err := w.Write([]byte("TRUE"))
+		return err
+	}
+	b := &strings.Builder{}
+	for i, e := range cxt.Deleted {
+		if i > 0 {
+			b.WriteString(" AND ")
+		}
+		if len(args) > 0 {
+			b.WriteString(args[0])
+			b.WriteString(".")
+		}
+		b.WriteString(e)
+		b.WriteString(" IS NULL")
+	}
+	_, err := w.Write([]byte(b.String()))
+	return err
+}
+
+// execJSON produces an expression which selects the element at a path into
+// a JSON column. The first argument is the column, which may be qualified,
+// and the remaining arguments are the object keys or array indexes which
+// make up the path. For example, {@json(p.attrs, tags, 0)} produces:
+//
+//	p.attrs->'tags'->0
+func execJSON(w io.Writer, cxt Context, args []string) error {
+	_, err := w.Write([]byte(jsonPath(args, false)))
+	return err
+}
+
+// execJSONText is like execJSON but produces the element at the end of the
+// path as text, which is convenient for comparisons. For example,
+// {@jsontext(p.attrs, owner, name)} produces:
+//
+//	p.attrs->'owner'->>'name'
+func execJSONText(w io.Writer, cxt Context, args []string) error {
+	_, err := w.Write([]byte(jsonPath(args, true)))
+	return err
+}
+
+func jsonPath(args []string, text bool) string {
+	b := &strings.Builder{}
+	b.WriteString(args[0])
+	for i, e := range args[1:] {
+		if text && i == len(args)-2 {
+			b.WriteString("->>")
+		} else {
+			b.WriteString("->")
+		}
+		b.WriteString(jsonKey(e))
+	}
+	return b.String()
+}
+
+// jsonKey produces the SQL representation of a path element. Integers are
+// array indexes, parameters like $1 and a single well-formed string literal
+// are used verbatim and anything else is quoted as an object key.
+func jsonKey(k string) string {
+	if _, err := strconv.Atoi(k); err == nil {
+		return k
+	}
+	if l := len(k); l > 1 && k[0] == variable {
+		if _, err := strconv.ParseUint(k[1:], 10, 32); err == nil {
+			return k
+		}
+	}
+	if l := len(k); l > 1 && k[0] == '\'' && k[l-1] == '\'' {
+		if !strings.Contains(strings.ReplaceAll(k[1:l-1], "''", ""), "'") {
+			return k
+		}
+	}
+	return "'" + strings.ReplaceAll(k, "'", "''") + "'"
+}
+
+// execAny produces a predicate which matches a column against any element of
+// an array. The first argument is the column, which may be qualified, and the
+// second is the array, usually a parameter. For example, {@any(p.id, $1)}
+// produces:
+//
+//	p.id = ANY($1)
+func execAny(w io.Writer, cxt Context, args []string) error {
+	_, err := w.Write([]byte(args[0] + " = ANY(" + args[1] + ")"))
+	return err
+}
@@ -6,12 +6,14 @@ import (
 )
 
 var (
-	EOF                = errors.New("EOF")
-	ErrInvalidEscape   = errors.New("Invalid escape sequence")
-	ErrInvalidIdent    = errors.New("Invalid identifier")
-	ErrInvalidQName    = errors.New("Invalid qualified name")
-	ErrUnexpectedToken = errors.New("Unexpected token")
-	ErrUnexpectedEOF   = errors.New("Unexpected end-of-file")
+	EOF                 = errors.New("EOF")
+	ErrInvalidEscape    = errors.New("Invalid escape sequence")
+	ErrInvalidIdent     = errors.New("Invalid identifier")
+	ErrInvalidQName     = errors.New("Invalid qualified name")
+	ErrUnexpectedToken  = errors.New("Unexpected token")
+	ErrUnexpectedEOF    = errors.New("Unexpected end-of-file")
+	ErrUnknownDirective = errors.New("Unknown directive")
+	ErrInvalidArgCount  = errors.New("Invalid argument count")
 )
 
 type Error struct {
@@ -23,6 +25,10 @@ func (e Error) Error() string {
 	return fmt.Sprintf("%v %s", e.error.Error(), e.span.Describe())
 }
 
+func (e Error) Unwrap() error {
+	return e.error
+}
+
 func newErr(c error, s Span) *Error {
 	return &Error{
 		error: c,
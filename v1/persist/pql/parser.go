@@ -6,8 +6,9 @@ import (
 )
 
 const (
-	wildcard = "*"
-	variable = '$'
+	wildcard  = "*"
+	variable  = '$'
+	directive = '@'
 )
 
 func Parse(t string) (*Program, error) {
@@ -128,6 +129,8 @@ func parseExpr(s *Scanner) (Node, error) {
 	switch s.Peek() {
 	case variable:
 		return parseVariableExpr(s)
+	case directive:
+		return parseDirectiveExpr(s)
 	default:
 		return parseColumnExpr(s)
 	}
@@ -151,6 +154,86 @@ func parseVariableExpr(s *Scanner) (Node, error) {
 	}, nil
 }
 
+func parseDirectiveExpr(s *Scanner) (Node, error) {
+	a := s.index
+
+	if s.Next() != directive {
+		return nil, newErr(ErrUnexpectedToken, NewSpan(s.text, s.index, 1))
+	}
+
+	name, err := parseIdent(s)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := directives[name]
+	if !ok {
+		return nil, newErr(ErrUnknownDirective, NewSpan(s.text, a, s.index-a))
+	}
+
+	var args []string
+	if s.SkipWhite().Peek() == '(' {
+		args, err = parseArgs(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(args) < d.min || (d.max >= 0 && len(args) > d.max) {
+		return nil, newErr(ErrInvalidArgCount, NewSpan(s.text, a, s.index-a))
+	}
+
+	return directiveNode{
+		node: newNode(s.text, a, s.index-a),
+		name: name,
+		args: args,
+	}, nil
+}
+
+// parseArgs parses a parenthesized, comma-separated list of arguments. The
+// arguments are not interpreted; the text of each is trimmed of whitespace.
+// parseArgs parses the parenthesized, comma-separated arguments of a
+// directive. Commas and parentheses within string literals, parenthesized
+// expressions and array subscripts don't delimit arguments. A quote opens a
+// string literal only if it doesn't follow an identifier, so that a bare key
+// like it's is still a single argument.
+func parseArgs(s *Scanner) ([]string, error) {
+	if s.Next() != '(' {
+		return nil, newErr(ErrUnexpectedToken, NewSpan(s.text, s.index, 1))
+	}
+	var args []string
+	var quoted bool
+	var depth int
+	var last rune
+	a := s.index
+	for {
+		c := s.Next()
+		switch {
+		case c == eof:
+			return nil, newErr(ErrUnexpectedEOF, NewSpan(s.text, s.index, 0))
+		case quoted:
+			quoted = c != '\''
+		case c == '\'':
+			quoted = !isIdent(last)
+		case c == '(' || c == '[':
+			depth++
+		case (c == ')' || c == ']') && depth > 0:
+			depth--
+		case (c == ',' || c == ')') && depth == 0:
+			arg := strings.TrimSpace(s.text[a : s.index-1])
+			if arg == "" && (c == ',' || len(args) > 0) {
+				return nil, newErr(ErrUnexpectedToken, NewSpan(s.text, s.index-1, 1))
+			}
+			if arg != "" {
+				args = append(args, arg)
+			}
+			if c == ')' {
+				return args, nil
+			}
+			a = s.index
+		}
+		last = c
+	}
+}
+
 func parseColumnExpr(s *Scanner) (Node, error) {
 	a := s.index
 
@@ -219,7 +302,7 @@ func parseIdent(s *Scanner) (string, error) {
 	a := s.index
 	for {
 		c := s.Peek()
-		if c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c) {
+		if isIdent(c) {
 			s.Next()
 		} else {
 			break
@@ -230,3 +313,7 @@ func parseIdent(s *Scanner) (string, error) {
 	}
 	return s.text[a:s.index], nil
 }
+
+func isIdent(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
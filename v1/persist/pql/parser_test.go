@@ -5,6 +5,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/bww/go-dbx/v1"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -277,6 +278,243 @@ func TestParseExpr(t *testing.T) {
 			},
 			"XYZ, p.A, p.B, p.C",
 		},
+		{
+			`{@live}`,
+			exprListNode{
+				node: newNode(`{@live}`, 1, 5),
+				sub: []Node{
+					directiveNode{
+						node: newNode(`{@live}`, 1, 5),
+						name: "live",
+					},
+				},
+			},
+			nil,
+			Context{
+				Deleted: []string{"d"},
+			},
+			"d IS NULL",
+		},
+		{
+			`{@live}`,
+			exprListNode{
+				node: newNode(`{@live}`, 1, 5),
+				sub: []Node{
+					directiveNode{
+						node: newNode(`{@live}`, 1, 5),
+						name: "live",
+					},
+				},
+			},
+			nil,
+			Context{},
+			"TRUE",
+		},
+		{
+			`{@live( p )}`,
+			exprListNode{
+				node: newNode(`{@live( p )}`, 1, 10),
+				sub: []Node{
+					directiveNode{
+						node: newNode(`{@live( p )}`, 1, 10),
+						name: "live",
+						args: []string{"p"},
+					},
+				},
+			},
+			nil,
+			Context{
+				Deleted: []string{"d", "e"},
+			},
+			"p.d IS NULL AND p.e IS NULL",
+		},
+		{
+			`{@json(p.attrs, tags, 0)}`,
+			exprListNode{
+				node: newNode(`{@json(p.attrs, tags, 0)}`, 1, 23),
+				sub: []Node{
+					directiveNode{
+						node: newNode(`{@json(p.attrs, tags, 0)}`, 1, 23),
+						name: "json",
+						args: []string{"p.attrs", "tags", "0"},
+					},
+				},
+			},
+			nil,
+			Context{},
+			"p.attrs->'tags'->0",
+		},
+		{
+			`{@jsontext(attrs, owner, 'name', it's)}`,
+			exprListNode{
+				node: newNode(`{@jsontext(attrs, owner, 'name', it's)}`, 1, 37),
+				sub: []Node{
+					directiveNode{
+						node: newNode(`{@jsontext(attrs, owner, 'name', it's)}`, 1, 37),
+						name: "jsontext",
+						args: []string{"attrs", "owner", "'name'", "it's"},
+					},
+				},
+			},
+			nil,
+			Context{},
+			"attrs->'owner'->'name'->>'it''s'",
+		},
+		{
+			`{@jsontext(c, 'a,b')}`,
+			exprListNode{
+				node: newNode(`{@jsontext(c, 'a,b')}`, 1, 19),
+				sub: []Node{
+					directiveNode{
+						node: newNode(`{@jsontext(c, 'a,b')}`, 1, 19),
+						name: "jsontext",
+						args: []string{"c", "'a,b'"},
+					},
+				},
+			},
+			nil,
+			Context{},
+			"c->>'a,b'",
+		},
+		{
+			`{@jsontext(c, 'a)'', (b')}`,
+			exprListNode{
+				node: newNode(`{@jsontext(c, 'a)'', (b')}`, 1, 24),
+				sub: []Node{
+					directiveNode{
+						node: newNode(`{@jsontext(c, 'a)'', (b')}`, 1, 24),
+						name: "jsontext",
+						args: []string{"c", "'a)'', (b'"},
+					},
+				},
+			},
+			nil,
+			Context{},
+			"c->>'a)'', (b'",
+		},
+		{
+			`{@json(attrs, tags, $1)}`,
+			exprListNode{
+				node: newNode(`{@json(attrs, tags, $1)}`, 1, 22),
+				sub: []Node{
+					directiveNode{
+						node: newNode(`{@json(attrs, tags, $1)}`, 1, 22),
+						name: "json",
+						args: []string{"attrs", "tags", "$1"},
+					},
+				},
+			},
+			nil,
+			Context{},
+			"attrs->'tags'->$1",
+		},
+		{
+			`{@table}`,
+			exprListNode{
+				node: newNode(`{@table}`, 1, 6),
+				sub: []Node{
+					directiveNode{
+						node: newNode(`{@table}`, 1, 6),
+						name: "table",
+					},
+				},
+			},
+			nil,
+			Context{
+				Table: "users",
+			},
+			"users",
+		},
+		{
+			`{@table(p)}`,
+			nil,
+			newErr(ErrInvalidArgCount, NewSpan(`{@table(p)}`, 1, 9)),
+			Context{},
+			"",
+		},
+		{
+			`{@any(p.id, $1)}`,
+			exprListNode{
+				node: newNode(`{@any(p.id, $1)}`, 1, 14),
+				sub: []Node{
+					directiveNode{
+						node: newNode(`{@any(p.id, $1)}`, 1, 14),
+						name: "any",
+						args: []string{"p.id", "$1"},
+					},
+				},
+			},
+			nil,
+			Context{},
+			"p.id = ANY($1)",
+		},
+		{
+			`{@any(x, ARRAY[1,2])}`,
+			exprListNode{
+				node: newNode(`{@any(x, ARRAY[1,2])}`, 1, 19),
+				sub: []Node{
+					directiveNode{
+						node: newNode(`{@any(x, ARRAY[1,2])}`, 1, 19),
+						name: "any",
+						args: []string{"x", "ARRAY[1,2]"},
+					},
+				},
+			},
+			nil,
+			Context{},
+			"x = ANY(ARRAY[1,2])",
+		},
+		{
+			`{@any(x, array_agg(f(a, b)))}`,
+			exprListNode{
+				node: newNode(`{@any(x, array_agg(f(a, b)))}`, 1, 27),
+				sub: []Node{
+					directiveNode{
+						node: newNode(`{@any(x, array_agg(f(a, b)))}`, 1, 27),
+						name: "any",
+						args: []string{"x", "array_agg(f(a, b))"},
+					},
+				},
+			},
+			nil,
+			Context{},
+			"x = ANY(array_agg(f(a, b)))",
+		},
+		{
+			`{@jsontext(c, 'a)}`,
+			nil,
+			newErr(ErrUnexpectedEOF, NewSpan(`{@jsontext(c, 'a)}`, 18, 0)),
+			Context{},
+			"",
+		},
+		{
+			`{@json(attrs)}`,
+			nil,
+			newErr(ErrInvalidArgCount, NewSpan(`{@json(attrs)}`, 1, 12)),
+			Context{},
+			"",
+		},
+		{
+			`{@nope}`,
+			nil,
+			newErr(ErrUnknownDirective, NewSpan(`{@nope}`, 1, 5)),
+			Context{},
+			"",
+		},
+		{
+			`{@live(p, q)}`,
+			nil,
+			newErr(ErrInvalidArgCount, NewSpan(`{@live(p, q)}`, 1, 11)),
+			Context{},
+			"",
+		},
+		{
+			`{@live(p,)}`,
+			nil,
+			newErr(ErrUnexpectedToken, NewSpan(`{@live(p,)}`, 9, 1)),
+			Context{},
+			"",
+		},
 	}
 	for _, e := range tests {
 		fmt.Println(">>>", e.Text)
@@ -298,6 +536,15 @@ func TestParseExpr(t *testing.T) {
 	}
 }
 
+func TestExecDirectiveError(t *testing.T) {
+	n, err := parseMeta(NewScanner(`{@table}`))
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		err = n.Exec(&strings.Builder{}, Context{})
+		assert.Equal(t, newErr(dbx.ErrNoTable, NewSpan(`{@table}`, 1, 6)), err)
+		assert.ErrorIs(t, err, dbx.ErrNoTable)
+	}
+}
+
 func TestParseProgram(t *testing.T) {
 	tests := []struct {
 		Text    string
@@ -418,3 +665,24 @@ func TestParseProgram(t *testing.T) {
 		}
 	}
 }
+
+func TestJSONKey(t *testing.T) {
+	tests := []struct {
+		Key    string
+		Expect string
+	}{
+		{"0", "0"},
+		{"name", "'name'"},
+		{"it's", "'it''s'"},
+		{"'name'", "'name'"},
+		{"'it''s'", "'it''s'"},
+		{"'a' || x || 'b'", "'''a'' || x || ''b'''"},
+		{"''", "''"},
+		{"$1", "$1"},
+		{"$", "'$'"},
+		{"$x", "'$x'"},
+	}
+	for _, e := range tests {
+		assert.Equal(t, e.Expect, jsonKey(e.Key), e.Key)
+	}
+}
@@ -0,0 +1,168 @@
+package persist
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bww/go-dbx/v1"
+	"github.com/bww/go-dbx/v1/entity"
+	"github.com/bww/go-dbx/v1/query"
+)
+
+// Repository provides typed access to entities of type T which are stored
+// in a particular table. It is a thin layer over a Persister; all operations
+// are delegated to it, so its configuration, the registry and any related
+// persisters behave exactly as they do when the Persister is used directly.
+type Repository[T any] struct {
+	pst   Persister
+	table string
+}
+
+// NewRepository creates a repository for entities stored in the provided
+// table. If the table is empty, the table declared by T is used; if T does
+// not declare one, ErrNoTable is returned.
+func NewRepository[T any](pst Persister, table string) (*Repository[T], error) {
+	table, err := tableFor(table, reflect.TypeOf((*T)(nil)))
+	if err != nil {
+		return nil, err
+	}
+	return &Repository[T]{pst: pst, table: table}, nil
+}
+
+// WithContext produces a copy of the repository operating in the provided
+// context, which is typically a transaction.
+func (r *Repository[T]) WithContext(cxt dbx.Context) *Repository[T] {
+	return &Repository[T]{pst: r.pst.WithContext(cxt), table: r.table}
+}
+
+// WithOptions produces a copy of the repository with the provided persister
+// options applied.
+func (r *Repository[T]) WithOptions(opts ...Option) *Repository[T] {
+	return &Repository[T]{pst: r.pst.WithOptions(opts...), table: r.table}
+}
+
+func (r *Repository[T]) Persister() Persister {
+	return r.pst
+}
+
+func (r *Repository[T]) Table() string {
+	return r.table
+}
+
+func (r *Repository[T]) Fetch(id interface{}) (*T, error) {
+	ent := new(T)
+	err := r.pst.Fetch(r.table, ent, id)
+	if err != nil {
+		return nil, err
+	}
+	return ent, nil
+}
+
+func (r *Repository[T]) Select(query string, args ...interface{}) ([]*T, error) {
+	var ents []*T
+	err := r.pst.Select(&ents, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return ents, nil
+}
+
+func (r *Repository[T]) Store(ent *T, cols []string) error {
+	return r.pst.Store(r.table, ent, cols)
+}
+
+func (r *Repository[T]) Insert(ent *T) error {
+	return r.pst.Insert(r.table, ent)
+}
+
+func (r *Repository[T]) Update(ent *T, cols []string) error {
+	return r.pst.Update(r.table, ent, cols)
+}
+
+func (r *Repository[T]) StoreMany(ents []*T) error {
+	return r.pst.StoreMany(r.table, ents)
+}
+
+func (r *Repository[T]) Delete(ent *T) error {
+	return r.pst.Delete(r.table, ent)
+}
+
+func (r *Repository[T]) DeleteWithID(id interface{}) error {
+	return r.pst.DeleteWithID(r.table, r.typ(), id)
+}
+
+func (r *Repository[T]) HardDelete(ent *T) error {
+	return r.pst.HardDelete(r.table, ent)
+}
+
+func (r *Repository[T]) Restore(ent *T) error {
+	return r.pst.Restore(r.table, ent)
+}
+
+// Count produces the number of entities in the table, excluding those which
+// have been soft deleted. If the persister is scoped to a tenant, only that
+// tenant's entities are counted.
+func (r *Repository[T]) Count() (int, error) {
+	where, args := r.where()
+	return r.pst.Count(`SELECT COUNT(*) FROM `+r.table+where, args...)
+}
+
+// List selects the entities in the table, ordered by primary key in the
+// direction and limited to the range described by the read configuration.
+// The timeframe of the configuration is not considered, since the repository
+// has no way to know which column, if any, it should apply to. Soft deleted
+// entities are not listed. If the persister is scoped to a tenant, only that
+// tenant's entities are listed.
+func (r *Repository[T]) List(conf query.ReadConfig) ([]*T, error) {
+	where, args := r.where()
+
+	b := &strings.Builder{}
+	b.WriteString(`SELECT {*} FROM `)
+	b.WriteString(r.table)
+	b.WriteString(where)
+
+	keys := r.pst.FieldMapper().KeysForType(r.typ())
+	sort.Strings(keys)
+	for i, e := range keys {
+		if i == 0 {
+			b.WriteString(` ORDER BY `)
+		} else {
+			b.WriteString(`, `)
+		}
+		b.WriteString(e)
+		b.WriteString(` `)
+		b.WriteString(conf.Order.String())
+	}
+
+	if l := conf.Limit.Length; l > 0 {
+		b.WriteString(` LIMIT `)
+		b.WriteString(strconv.Itoa(l))
+	}
+	if o := conf.Limit.Offset; o > 0 {
+		b.WriteString(` OFFSET `)
+		b.WriteString(strconv.Itoa(o))
+	}
+
+	return r.Select(b.String(), args...)
+}
+
+func (r *Repository[T]) typ() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// where produces a WHERE clause restricting a query to the tenant the
+// persister is scoped to, if any, and to entities which have not been soft
+// deleted, and the arguments it references.
+func (r *Repository[T]) where() (string, []interface{}) {
+	fm := r.pst.FieldMapper()
+	where, args := entity.NewGenerator(fm).Where(
+		entity.WithWhere(tenantColumns(fm, r.pst.Config().Tenant, r.typ())),
+		entity.WithWhere(liveColumns(fm, r.typ())),
+	)
+	if where == "" {
+		return "", nil
+	}
+	return " " + where, args
+}
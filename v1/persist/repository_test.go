@@ -0,0 +1,75 @@
+package persist
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/bww/go-dbx/v1"
+	"github.com/bww/go-dbx/v1/entity"
+	"github.com/bww/go-dbx/v1/persist/ident"
+	"github.com/bww/go-dbx/v1/persist/registry"
+	"github.com/bww/go-dbx/v1/query"
+	"github.com/bww/go-dbx/v1/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32)).WithOptions(Tenant("tenant_repo"))
+	repo, err := NewRepository[tenantEntity](pst, tenantTable)
+	if !assert.Nil(t, err, fmt.Sprint(err)) {
+		return
+	}
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		e := &tenantEntity{B: fmt.Sprintf("Entity #%d", i)}
+		err = repo.Store(e, nil)
+		if assert.Nil(t, err, fmt.Sprint(err)) {
+			ids = append(ids, e.A)
+		}
+	}
+	sort.Strings(ids)
+
+	n, err := repo.Count()
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, 5, n)
+	}
+
+	e1, err := repo.Fetch(ids[0])
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, ids[0], e1.A)
+		assert.Equal(t, "tenant_repo", e1.T)
+	}
+
+	_, err = repo.Fetch("THIS IS NOT A VALID IDENT, BRAH")
+	assert.Equal(t, dbx.ErrNotFound, err)
+
+	l1, err := repo.List(query.NewReadConfig([]query.ReadOption{query.WithLimit(query.Range{Offset: 1, Length: 2})}))
+	if assert.Nil(t, err, fmt.Sprint(err)) && assert.Len(t, l1, 2) {
+		assert.Equal(t, ids[1], l1[0].A)
+		assert.Equal(t, ids[2], l1[1].A)
+	}
+
+	l2, err := repo.List(query.NewReadConfig([]query.ReadOption{query.WithOrder(query.Descending)}))
+	if assert.Nil(t, err, fmt.Sprint(err)) && assert.Len(t, l2, 5) {
+		assert.Equal(t, ids[4], l2[0].A)
+		assert.Equal(t, ids[0], l2[4].A)
+	}
+
+	s1, err := repo.Select(`SELECT {*} FROM `+tenantTable+` WHERE a = $1`, ids[3])
+	if assert.Nil(t, err, fmt.Sprint(err)) && assert.Len(t, s1, 1) {
+		assert.Equal(t, ids[3], s1[0].A)
+	}
+
+	err = repo.Delete(e1)
+	assert.Nil(t, err, fmt.Sprint(err))
+	err = repo.DeleteWithID(ids[1])
+	assert.Nil(t, err, fmt.Sprint(err))
+
+	n, err = repo.Count()
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, 3, n)
+	}
+}
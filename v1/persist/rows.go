@@ -7,6 +7,7 @@ import (
 	"github.com/bww/go-dbx/v1"
 	"github.com/bww/go-dbx/v1/entity"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type Row struct {
@@ -45,7 +46,7 @@ func (r *Row) ScanStruct(dest interface{}) error {
 	values := make([]interface{}, len(columns))
 
 	// initialize for scanning
-	err = fieldsByTraversal(v, fields, omits, temps, values, true)
+	err = fieldsByTraversal(m, v, fields, omits, temps, values, true)
 	if err != nil {
 		return err
 	}
@@ -59,7 +60,7 @@ func (r *Row) ScanStruct(dest interface{}) error {
 	}
 
 	// copy over valid omittable values to their fields
-	err = finalizeFields(v, fields, temps, values)
+	err = finalizeFields(m, v, fields, temps, values)
 	if err != nil {
 		return err
 	}
@@ -80,6 +81,13 @@ func newRows(r *sqlx.Rows, m *entity.FieldMapper) *Rows {
 	return &Rows{Rows: r, mapper: m}
 }
 
+// reset replaces the underlying result set while retaining the traversal
+// cached by a previous scan. The new result set must have the same columns
+// and be scanned into the same type.
+func (r *Rows) reset(rows *sqlx.Rows) {
+	r.Rows = rows
+}
+
 func (r *Rows) ScanStruct(dest interface{}) error {
 	v := reflect.ValueOf(dest)
 	if v.Kind() != reflect.Ptr {
@@ -99,7 +107,7 @@ func (r *Rows) ScanStruct(dest interface{}) error {
 	}
 
 	// initialize for scanning
-	err := fieldsByTraversal(v, r.fields, r.omits, r.temps, r.values, true)
+	err := fieldsByTraversal(r.mapper, v, r.fields, r.omits, r.temps, r.values, true)
 	if err != nil {
 		return err
 	}
@@ -111,7 +119,7 @@ func (r *Rows) ScanStruct(dest interface{}) error {
 	}
 
 	// copy over valid omittable values to their fields
-	err = finalizeFields(v, r.fields, r.temps, r.values)
+	err = finalizeFields(r.mapper, v, r.fields, r.temps, r.values)
 	if err != nil {
 		return err
 	}
@@ -119,7 +127,7 @@ func (r *Rows) ScanStruct(dest interface{}) error {
 	return r.Err()
 }
 
-func fieldsByTraversal(v reflect.Value, fields [][]int, omits, temps []bool, values []interface{}, ptrs bool) error {
+func fieldsByTraversal(m *entity.FieldMapper, v reflect.Value, fields [][]int, omits, temps []bool, values []interface{}, ptrs bool) error {
 	v = reflect.Indirect(v)
 	if v.Kind() != reflect.Struct {
 		return dbx.ErrNotAStruct
@@ -129,7 +137,15 @@ func fieldsByTraversal(v reflect.Value, fields [][]int, omits, temps []bool, val
 			values[i], temps[i] = new(interface{}), false
 		} else {
 			f := entity.FieldByIndexes(v, field)
-			if omits[i] && ptrs && f.Kind() != reflect.Ptr {
+			if ptrs && m.IsEncrypted(v.Type(), field) {
+				values[i], temps[i] = m.NewDecrypter(f), false
+			} else if ptrs && m.IsJSON(v.Type(), field) {
+				values[i], temps[i] = entity.NewJSONScanner(f), false
+			} else if ptrs && m.IsArray(v.Type(), field) {
+				values[i], temps[i] = pq.Array(f.Addr().Interface()), false
+			} else if ptrs && m.Converts(f.Type()) { // scanned as-is and converted when finalized
+				values[i], temps[i] = new(interface{}), true
+			} else if omits[i] && ptrs && f.Kind() != reflect.Ptr {
 				values[i], temps[i] = reflect.New(reflect.PtrTo(f.Type())).Interface(), true
 			} else if ptrs {
 				values[i], temps[i] = f.Addr().Interface(), false
@@ -141,12 +157,17 @@ func fieldsByTraversal(v reflect.Value, fields [][]int, omits, temps []bool, val
 	return nil
 }
 
-func finalizeFields(v reflect.Value, fields [][]int, temps []bool, values []interface{}) error {
+func finalizeFields(m *entity.FieldMapper, v reflect.Value, fields [][]int, temps []bool, values []interface{}) error {
 	for i, e := range temps {
 		if e {
 			t := reflect.Indirect(reflect.ValueOf(values[i]))
 			f := entity.FieldByIndexes(v, fields[i])
-			if !t.IsNil() {
+			if x, ok := values[i].(*interface{}); ok {
+				err := m.ConvertFrom(f, *x)
+				if err != nil {
+					return err
+				}
+			} else if !t.IsNil() {
 				f.Set(reflect.Indirect(t))
 			} else { // explicitly set the zero value if the value is missing
 				f.Set(reflect.Zero(f.Type()))
@@ -0,0 +1,27 @@
+//go:build go1.23
+
+package persist
+
+import (
+	"iter"
+	"reflect"
+
+	"github.com/bww/go-dbx/v1"
+)
+
+// Seq produces an iterator over the entities of type T selected by a PQL
+// query. Like Each, entities are scanned one at a time as they are consumed.
+// If an error occurs, it is yielded with a nil entity and iteration ends.
+func Seq[T any](pst Persister, query string, args ...interface{}) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		err := pst.Each(reflect.TypeOf((*T)(nil)), func(ent interface{}) error {
+			if !yield(ent.(*T), nil) {
+				return dbx.ErrStopIteration
+			}
+			return nil
+		}, query, args...)
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
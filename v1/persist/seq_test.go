@@ -0,0 +1,40 @@
+//go:build go1.23
+
+package persist
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bww/go-dbx/v1/entity"
+	"github.com/bww/go-dbx/v1/persist/ident"
+	"github.com/bww/go-dbx/v1/persist/registry"
+	"github.com/bww/go-dbx/v1/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersistSeq(t *testing.T) {
+	db := test.DB()
+	pst := New(db, entity.NewFieldMapper(), registry.New(), ident.AlphaNumeric(32))
+
+	for i := 0; i < 10; i++ {
+		_, err := pst.Exec(`INSERT INTO `+fourthTable+` (x, z) VALUES ($1, $2) ON CONFLICT (x) DO UPDATE SET z = $2`, fmt.Sprintf("seq_%d", i), i)
+		assert.Nil(t, err, fmt.Sprint(err))
+	}
+
+	var n int
+	for e, err := range Seq[secondEntity](pst, `SELECT {*} FROM `+fourthTable+` WHERE x LIKE 'seq_%' ORDER BY z`) {
+		if !assert.Nil(t, err, fmt.Sprint(err)) {
+			break
+		}
+		assert.Equal(t, n, e.Z)
+		if n++; n == 5 {
+			break
+		}
+	}
+	assert.Equal(t, 5, n)
+
+	for _, err := range Seq[secondEntity](pst, `SELECT {*} FROM no_such_table`) {
+		assert.NotNil(t, err, "Expected an error")
+	}
+}
@@ -0,0 +1,121 @@
+package persist
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/bww/go-dbx/v1"
+	"github.com/bww/go-dbx/v1/entity"
+	"github.com/bww/go-dbx/v1/errors"
+)
+
+// softDeletable determines whether an entity type declares soft delete
+// columns.
+func (p *persister) softDeletable(typ reflect.Type) bool {
+	return len(p.fm.DeletedForType(typ)) > 0
+}
+
+// liveColumns produces the conditions which exclude soft deleted rows of an
+// entity type. If the type does not support soft deletion, nil is returned.
+func (p *persister) liveColumns(typ reflect.Type) *entity.Columns {
+	return liveColumns(p.fm, typ)
+}
+
+func liveColumns(fm *entity.FieldMapper, typ reflect.Type) *entity.Columns {
+	cols := fm.DeletedForType(typ)
+	if len(cols) == 0 {
+		return nil
+	}
+	return &entity.Columns{Cols: cols, Vals: make([]interface{}, len(cols))}
+}
+
+// softDelete marks an entity as deleted as of the current time.
+func (p *persister) softDelete(table string, ent interface{}) error {
+	keys, _ := p.fm.Columns(ent)
+	if len(keys.Cols) < 1 {
+		return dbx.ErrInvalidKeyCount
+	}
+
+	now := dbx.Now()
+	err := p.markDeleted(table, reflect.TypeOf(ent), keys, &now)
+	if err != nil {
+		return err
+	}
+	err = p.setDeleted(ent, &now)
+	if err != nil {
+		return err
+	}
+
+	p.fm.Snapshot(ent)
+	return nil
+}
+
+// Restore restores an entity which has been soft deleted. Entities which do
+// not support soft deletion produce ErrInvalidField.
+func (p *persister) Restore(table string, ent interface{}) error {
+	typ := reflect.TypeOf(ent)
+	if !p.softDeletable(typ) {
+		return dbx.ErrInvalidField
+	}
+
+	table, err := tableFor(table, typ)
+	if err != nil {
+		return err
+	}
+
+	keys, _ := p.fm.Columns(ent)
+	if len(keys.Cols) < 1 {
+		return dbx.ErrInvalidKeyCount
+	}
+
+	err = p.markDeleted(table, typ, keys, nil)
+	if err != nil {
+		return err
+	}
+	err = p.setDeleted(ent, nil)
+	if err != nil {
+		return err
+	}
+
+	p.fm.Snapshot(ent)
+	return nil
+}
+
+// markDeleted sets the soft delete columns of the row identified by keys to
+// the provided time, or to NULL if it is nil.
+func (p *persister) markDeleted(table string, typ reflect.Type, keys *entity.Columns, at *time.Time) error {
+	cols := p.fm.DeletedForType(typ)
+	vals := make([]interface{}, len(cols))
+	for i := range vals {
+		vals[i] = at
+	}
+
+	tcols := p.tenantColumns(typ)
+	sql, args := p.gen.Assign(table, &entity.Columns{Cols: cols, Vals: vals}, keys, entity.WithWhere(tcols))
+	res, err := p.Context.Exec(sql, args...)
+	if err != nil {
+		return errors.NewWithSQL(err, sql)
+	}
+
+	if tcols != nil {
+		n, err := res.RowsAffected()
+		if err != nil {
+			return errors.NewWithSQL(err, sql)
+		}
+		if n == 0 {
+			return p.crossTenant(table, keys, tcols)
+		}
+	}
+
+	return nil
+}
+
+// setDeleted updates the soft delete fields of an entity to reflect the
+// change made to its row.
+func (p *persister) setDeleted(ent interface{}, at *time.Time) error {
+	vals, err := p.fm.Deleted(ent)
+	if err != nil {
+		return err
+	}
+	return setTime(vals, at, false)
+}
@@ -0,0 +1,102 @@
+package persist
+
+import (
+	dbsql "database/sql"
+	"reflect"
+	"time"
+
+	"github.com/bww/go-dbx/v1"
+	"github.com/bww/go-dbx/v1/entity"
+)
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	timePtrType = reflect.TypeOf((*time.Time)(nil))
+)
+
+// stamp sets the creation and update time fields of an entity which is about
+// to be written. Creation times are only set when an entity is inserted, and
+// then only if they have not already been set. An upsert may turn out to
+// update an existing row, which keeps its creation time; that time is read
+// back once the entity is written.
+func (p *persister) stamp(ent interface{}, insert bool, at time.Time) error {
+	if insert {
+		vals, err := p.fm.Created(ent)
+		if err != nil {
+			return err
+		}
+		err = setTime(vals, &at, true)
+		if err != nil {
+			return err
+		}
+	}
+	vals, err := p.fm.Updated(ent)
+	if err != nil {
+		return err
+	}
+	return setTime(vals, &at, false)
+}
+
+// withUpdated produces the provided columns plus the update time columns of
+// a type, which must be written along with anything else.
+func (p *persister) withUpdated(typ reflect.Type, cols []string) []string {
+	ucols := p.fm.UpdatedForType(typ)
+	if len(ucols) == 0 {
+		return cols
+	}
+	res := append(make([]string, 0, len(cols)+len(ucols)), cols...)
+outer:
+	for _, e := range ucols {
+		for _, c := range cols {
+			if c == e {
+				continue outer
+			}
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+// setTime sets time fields to the provided time, or clears them if it is nil.
+// Fields may be a time.Time, a *time.Time, or any type which can scan a time,
+// such as sql.NullTime. If zeroOnly is set, fields which already have a value
+// are left as they are.
+func setTime(vals *entity.Values, at *time.Time, zeroOnly bool) error {
+	for _, f := range vals.Vals {
+		if !f.IsValid() || !f.CanSet() {
+			return dbx.ErrInvalidField
+		}
+		if zeroOnly && !f.IsZero() {
+			continue
+		}
+		switch f.Type() {
+		case timeType:
+			if at != nil {
+				f.Set(reflect.ValueOf(*at))
+			} else {
+				f.Set(reflect.Zero(timeType))
+			}
+		case timePtrType:
+			if at != nil {
+				t := *at
+				f.Set(reflect.ValueOf(&t))
+			} else {
+				f.Set(reflect.Zero(timePtrType))
+			}
+		default:
+			s, ok := f.Addr().Interface().(dbsql.Scanner)
+			if !ok {
+				return dbx.ErrInvalidField
+			}
+			var v interface{}
+			if at != nil {
+				v = *at
+			}
+			err := s.Scan(v)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
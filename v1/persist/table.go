@@ -0,0 +1,47 @@
+package persist
+
+import (
+	"reflect"
+
+	"github.com/bww/go-dbx/v1"
+	"github.com/bww/go-dbx/v1/entity"
+)
+
+// tableFor resolves the table an entity is stored in. If a table is provided
+// it is used as-is; otherwise the table declared by the entity type is used.
+// If the type does not declare a table, ErrNoTable is returned.
+func tableFor(table string, typ reflect.Type) (string, error) {
+	if table != "" {
+		return table, nil
+	}
+	t, ok := entity.TableForType(typ)
+	if !ok {
+		return "", dbx.ErrNoTable
+	}
+	return t, nil
+}
+
+// StoreEntity stores an entity in the table declared by its type.
+func StoreEntity(pst Persister, ent interface{}, cols []string) error {
+	return pst.Store("", ent, cols)
+}
+
+// InsertEntity inserts an entity into the table declared by its type.
+func InsertEntity(pst Persister, ent interface{}) error {
+	return pst.Insert("", ent)
+}
+
+// UpdateEntity updates an entity in the table declared by its type.
+func UpdateEntity(pst Persister, ent interface{}, cols []string) error {
+	return pst.Update("", ent, cols)
+}
+
+// FetchEntity fetches an entity from the table declared by its type.
+func FetchEntity(pst Persister, ent, id interface{}) error {
+	return pst.Fetch("", ent, id)
+}
+
+// DeleteEntity deletes an entity from the table declared by its type.
+func DeleteEntity(pst Persister, ent interface{}) error {
+	return pst.Delete("", ent)
+}
@@ -0,0 +1,96 @@
+package persist
+
+import (
+	"reflect"
+
+	"github.com/bww/go-dbx/v1"
+	"github.com/bww/go-dbx/v1/entity"
+	"github.com/bww/go-dbx/v1/errors"
+)
+
+// tenantColumns produces the tenant conditions for an entity type. If the
+// persister is not scoped to a tenant or the type does not declare a tenant
+// column, nil is returned.
+func (p *persister) tenantColumns(typ reflect.Type) *entity.Columns {
+	return tenantColumns(p.fm, p.conf.Tenant, typ)
+}
+
+func tenantColumns(fm *entity.FieldMapper, tenant interface{}, typ reflect.Type) *entity.Columns {
+	if tenant == nil {
+		return nil
+	}
+	cols := fm.TenantForType(typ)
+	if len(cols) == 0 {
+		return nil
+	}
+	vals := make([]interface{}, len(cols))
+	for i := range vals {
+		vals[i] = tenant
+	}
+	return &entity.Columns{Cols: cols, Vals: vals}
+}
+
+// assignTenant sets the tenant columns of an entity to the tenant the
+// persister is scoped to. An entity which already belongs to a different
+// tenant produces ErrCrossTenant.
+func (p *persister) assignTenant(ent interface{}) error {
+	if p.conf.Tenant == nil {
+		return nil
+	}
+	vals, err := p.fm.Tenant(ent)
+	if err != nil {
+		return err
+	}
+	tv := reflect.ValueOf(p.conf.Tenant)
+	for _, f := range vals.Vals {
+		if !f.IsValid() || !f.CanSet() {
+			return dbx.ErrInvalidField
+		}
+		var v reflect.Value
+		if t := f.Type(); tv.Type().AssignableTo(t) {
+			v = tv
+		} else if tv.Kind() == t.Kind() && tv.Type().ConvertibleTo(t) {
+			v = tv.Convert(t)
+		} else {
+			return dbx.ErrInvalidField
+		}
+		if !f.IsZero() && !reflect.DeepEqual(f.Interface(), v.Interface()) {
+			return dbx.ErrCrossTenant
+		}
+		f.Set(v)
+	}
+	return nil
+}
+
+// crossTenant is invoked when a tenant-scoped operation matched no rows. If
+// a row identified by the keys exists regardless, and it is not one that
+// belongs to the tenant but was excluded for some other reason, it must
+// belong to another tenant and ErrCrossTenant is returned; otherwise the
+// result is nil.
+func (p *persister) crossTenant(table string, keys, tcols *entity.Columns) error {
+	found, err := p.exists(table, keys)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return nil
+	}
+	found, err = p.exists(table, keys, entity.WithWhere(tcols))
+	if err != nil {
+		return err
+	}
+	if !found {
+		return dbx.ErrCrossTenant
+	}
+	return nil
+}
+
+func (p *persister) exists(table string, keys *entity.Columns, opts ...entity.GenerateOption) (bool, error) {
+	sql, args := p.gen.Exists(table, keys, opts...)
+	var found bool
+	err := p.Context.QueryRow(sql, args...).Scan(&found)
+	if err != nil {
+		return false, errors.NewWithSQL(err, sql)
+	}
+	return found, nil
+}
@@ -0,0 +1,52 @@
+package persist
+
+import (
+	"reflect"
+
+	"github.com/bww/go-dbx/v1"
+)
+
+// versioned determines whether an entity type declares a version column.
+func (p *persister) versioned(typ reflect.Type) bool {
+	return len(p.fm.VersionForType(typ)) > 0
+}
+
+// initVersion sets the version fields of a new entity which are zero to the
+// initial version, one.
+func (p *persister) initVersion(ent interface{}) error {
+	return p.updateVersion(ent, func(v int64) int64 {
+		if v == 0 {
+			return 1
+		}
+		return v
+	})
+}
+
+// incrVersion increments the version fields of an entity, which mirrors the
+// change made to the row when it is updated.
+func (p *persister) incrVersion(ent interface{}) error {
+	return p.updateVersion(ent, func(v int64) int64 {
+		return v + 1
+	})
+}
+
+func (p *persister) updateVersion(ent interface{}, fn func(int64) int64) error {
+	vals, err := p.fm.Version(ent)
+	if err != nil {
+		return err
+	}
+	for _, f := range vals.Vals {
+		if !f.IsValid() || !f.CanSet() {
+			return dbx.ErrInvalidField
+		}
+		switch f.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f.SetInt(fn(f.Int()))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			f.SetUint(uint64(fn(int64(f.Uint()))))
+		default:
+			return dbx.ErrInvalidField
+		}
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package schema
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/bww/go-dbx/v1"
+	"github.com/bww/go-dbx/v1/entity"
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// DDLTag is the struct tag which overrides the column definition inferred
+// for a field when DDL is generated. It provides everything that follows
+// the column name, for example:
+//
+//	Name  string  `db:"name" ddl:"varchar(64) not null"`
+//	Price float64 `db:"price" ddl:"numeric(10, 2) not null default 0"`
+const DDLTag = "ddl"
+
+// columnDef describes the column a field is stored in.
+type columnDef struct {
+	Name     string
+	Type     string // the SQL type, or the entire definition if Override is set
+	Nullable bool
+	Identity bool // generated by the database as an identity column
+	Override bool // the type is a complete definition provided by the ddl tag
+}
+
+func (c columnDef) String() string {
+	if c.Override {
+		return c.Type
+	}
+	d := c.Type
+	if c.Identity {
+		d += " generated by default as identity"
+	}
+	if !c.Nullable {
+		d += " not null"
+	}
+	return d
+}
+
+// Types which are not inferred from their kind.
+var knownTypes = map[reflect.Type]columnDef{
+	typeOfTime:                        {Type: "timestamp with time zone"},
+	reflect.TypeOf(sql.NullString{}):  {Type: "text", Nullable: true},
+	reflect.TypeOf(sql.NullBool{}):    {Type: "boolean", Nullable: true},
+	reflect.TypeOf(sql.NullInt16{}):   {Type: "smallint", Nullable: true},
+	reflect.TypeOf(sql.NullInt32{}):   {Type: "integer", Nullable: true},
+	reflect.TypeOf(sql.NullInt64{}):   {Type: "bigint", Nullable: true},
+	reflect.TypeOf(sql.NullFloat64{}): {Type: "double precision", Nullable: true},
+	reflect.TypeOf(sql.NullTime{}):    {Type: "timestamp with time zone", Nullable: true},
+	reflect.TypeOf(sql.NullByte{}):    {Type: "smallint", Nullable: true},
+	reflect.TypeOf(sql.RawBytes(nil)): {Type: "bytea", Nullable: true},
+	reflect.TypeOf(json.RawMessage{}): {Type: "jsonb", Nullable: true},
+}
+
+// sqlType infers the SQL type of a Go type. The result is nullable if
+// the Go type can represent NULL.
+func sqlType(t reflect.Type) (columnDef, bool) {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t, nullable = t.Elem(), true
+	}
+	if d, ok := knownTypes[t]; ok {
+		d.Nullable = d.Nullable || nullable
+		return d, true
+	}
+	if t.Implements(typeOfValuer) || reflect.PointerTo(t).Implements(typeOfScanner) {
+		return columnDef{}, false // we can't know what it is stored as
+	}
+	var typ string
+	switch t.Kind() {
+	case reflect.Bool:
+		typ = "boolean"
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		typ = "smallint"
+	case reflect.Int32, reflect.Uint16:
+		typ = "integer"
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		typ = "bigint"
+	case reflect.Float32:
+		typ = "real"
+	case reflect.Float64:
+		typ = "double precision"
+	case reflect.String:
+		typ = "text"
+	case reflect.Slice:
+		if t.Elem().Kind() != reflect.Uint8 {
+			return columnDef{}, false
+		}
+		typ, nullable = "bytea", true
+	default:
+		return columnDef{}, false
+	}
+	return columnDef{Type: typ, Nullable: nullable}, true
+}
+
+// columnDefs produces the definitions of the columns of a mapping, in the
+// order their fields are declared.
+func columnDefs(fm *entity.FieldMapper, m Mapping) ([]columnDef, error) {
+	tmap := fm.TypeMap(m.Type)
+	cols := fm.ColumnsForType(m.Type)
+
+	keys := make(map[string]struct{})
+	for _, k := range fm.KeysForType(m.Type) {
+		keys[k] = struct{}{}
+	}
+	nullable := make(map[string]struct{})
+	for _, set := range [][]string{fm.DeletedForType(m.Type), fm.ColumnsForType(m.Type, omitsEmpty)} {
+		for _, k := range set {
+			nullable[k] = struct{}{}
+		}
+	}
+	generated := make(map[string]struct{})
+	for _, k := range fm.GeneratedForType(m.Type) {
+		generated[k] = struct{}{}
+	}
+
+	defs := make([]columnDef, 0, len(cols))
+	for _, n := range cols {
+		f := tmap.Names[n]
+		if d := f.Field.Tag.Get(DDLTag); d != "" {
+			defs = append(defs, columnDef{Name: n, Type: d, Override: true})
+			continue
+		}
+
+		var def columnDef
+		var ok bool
+		switch {
+		case fm.IsEncrypted(m.Type, f.Index):
+			def, ok = columnDef{Type: "bytea", Nullable: true}, true
+		case fm.IsJSON(m.Type, f.Index):
+			def, ok = columnDef{Type: "jsonb", Nullable: true}, true
+		case fm.IsArray(m.Type, f.Index):
+			t := f.Field.Type
+			for t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+				def, ok = sqlType(t.Elem())
+				def.Type, def.Nullable = def.Type+"[]", true
+			}
+		default:
+			def, ok = sqlType(f.Field.Type)
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: cannot infer the column type of %s.%s (%v); tag it with %s", dbx.ErrInvalidField, m.Type.Name(), f.Field.Name, f.Field.Type, DDLTag)
+		}
+
+		def.Name = n
+		if _, ok := nullable[n]; ok {
+			def.Nullable = true
+		}
+		if _, ok := keys[n]; ok {
+			def.Nullable = false
+		}
+		if _, ok := generated[n]; ok {
+			// only integer columns can be identities; any other generated value
+			// is produced by the database in some way we can't describe, so the
+			// column can't require one
+			if def.Type == "bigint" || def.Type == "integer" || def.Type == "smallint" {
+				def.Identity = true
+			} else {
+				def.Nullable = true
+			}
+		}
+		defs = append(defs, def)
+	}
+
+	return defs, nil
+}
+
+func omitsEmpty(f *reflectx.FieldInfo) bool {
+	_, ok := f.Options["omitempty"]
+	return ok
+}
+
+// CreateTable produces a CREATE TABLE statement for the table of a mapping.
+// Column types are inferred from the types of their fields unless they are
+// overridden by the ddl tag, and the primary key is declared by the fields
+// tagged with the pk option. For example:
+//
+//	type User struct {
+//	  Id      string    `db:"id,pk"`
+//	  Name    string    `db:"name" ddl:"varchar(64) not null"`
+//	  Created time.Time `db:"created_at,created"`
+//	}
+//
+// produces:
+//
+//	create table users (
+//	  id          text not null,
+//	  name        varchar(64) not null,
+//	  created_at  timestamp with time zone not null,
+//	  primary key (id)
+//	);
+func CreateTable(fm *entity.FieldMapper, m Mapping) (string, error) {
+	if m.Table == "" {
+		return "", dbx.ErrNoTable
+	}
+	defs, err := columnDefs(fm, m)
+	if err != nil {
+		return "", err
+	}
+
+	w := 0
+	for _, e := range defs {
+		w = max(w, len(e.Name))
+	}
+
+	b := &strings.Builder{}
+	b.WriteString("create table ")
+	b.WriteString(m.Table)
+	b.WriteString(" (\n")
+	for i, e := range defs {
+		if i > 0 {
+			b.WriteString(",\n")
+		}
+		fmt.Fprintf(b, "  %-*s  %s", w, e.Name, e)
+	}
+	if keys := fm.KeysForType(m.Type); len(keys) > 0 {
+		sortByColumn(keys, defs)
+		fmt.Fprintf(b, ",\n  primary key (%s)", strings.Join(keys, ", "))
+	}
+	b.WriteString("\n);\n")
+	return b.String(), nil
+}
+
+// sortByColumn orders names in the order the columns they identify are
+// defined.
+func sortByColumn(names []string, defs []columnDef) {
+	pos := make(map[string]int)
+	for i, e := range defs {
+		pos[e.Name] = i
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return pos[names[i]] < pos[names[j]]
+	})
+}
@@ -0,0 +1,151 @@
+package schema
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bww/go-dbx/v1"
+	"github.com/bww/go-dbx/v1/entity"
+	"github.com/bww/go-dbx/v1/test"
+	"github.com/stretchr/testify/assert"
+)
+
+type ddlEntity struct {
+	A string            `db:"a,pk"`
+	B string            `db:"b" ddl:"varchar(64)"`
+	C int               `db:"c,omitempty"`
+	D *float64          `db:"d"`
+	E time.Time         `db:"e,created"`
+	F *time.Time        `db:"f,deleted"`
+	G map[string]string `db:"g,json"`
+	H []int64           `db:"h,array"`
+	I string            `db:"i,encrypt"`
+	J sql.NullString    `db:"j"`
+	K bool              `db:"k"`
+	L int32             `db:"l,pk,generated"`
+	M string            `db:"m,generated"`
+}
+
+type badDDLEntity struct {
+	A chan int `db:"a"`
+}
+
+func TestCreateTable(t *testing.T) {
+	fm := entity.NewFieldMapper()
+
+	ddl, err := CreateTable(fm, Map("ddl_entity", ddlEntity{}))
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, `create table ddl_entity (
+  a  text not null,
+  b  varchar(64),
+  c  bigint,
+  d  double precision,
+  e  timestamp with time zone not null,
+  f  timestamp with time zone,
+  g  jsonb,
+  h  bigint[],
+  i  bytea,
+  j  text,
+  k  boolean not null,
+  l  integer generated by default as identity not null,
+  m  text,
+  primary key (a, l)
+);
+`, ddl)
+	}
+
+	_, err = CreateTable(fm, Map("", ddlEntity{}))
+	assert.ErrorIs(t, err, dbx.ErrNoTable)
+	_, err = CreateTable(fm, Map("bad", badDDLEntity{}))
+	assert.ErrorIs(t, err, dbx.ErrInvalidField)
+}
+
+func TestDiff(t *testing.T) {
+	fm := entity.NewFieldMapper()
+	table := &Table{
+		Name: "ddl_entity",
+		Columns: []Column{
+			{Name: "a", Type: "uuid"},
+			{Name: "b", Type: "text"},
+			{Name: "c", Type: "int4", Nullable: true},
+			{Name: "d", Type: "float8"},
+			{Name: "g", Type: "jsonb", Nullable: true},
+			{Name: "h", Type: "_int8", Nullable: true},
+			{Name: "i", Type: "bytea", Nullable: true},
+			{Name: "j", Type: "text", Nullable: true},
+			{Name: "k", Type: "text", Nullable: true},
+			{Name: "x", Type: "text", Nullable: true},
+		},
+		PrimaryKey: []string{"a"},
+	}
+
+	stmts, err := Diff(fm, Map("ddl_entity", ddlEntity{}), table)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, []string{
+			"alter table ddl_entity alter column d drop not null;",
+			"alter table ddl_entity add column e timestamp with time zone;",
+			"-- alter table ddl_entity alter column e set not null; -- once every row has a value",
+			"alter table ddl_entity add column f timestamp with time zone;",
+			"-- alter table ddl_entity alter column k type boolean; -- bool cannot be stored as text",
+			"-- alter table ddl_entity alter column k set not null; -- once every row has a value",
+			"alter table ddl_entity add column l integer generated by default as identity not null;",
+			"alter table ddl_entity add column m text;",
+			"-- alter table ddl_entity drop column x; -- not mapped",
+			"-- primary key of ddl_entity is (a) but is mapped as (a, l)",
+		}, stmts)
+	}
+
+	// a table without a primary key may have rows which conflict with the one
+	// that is mapped, so it is only suggested
+	table.PrimaryKey = nil
+	stmts, err = Diff(fm, Map("ddl_entity", ddlEntity{}), table)
+	if assert.Nil(t, err, fmt.Sprint(err)) && assert.NotEmpty(t, stmts) {
+		assert.Equal(t, "-- alter table ddl_entity add primary key (a, l); -- once the key columns are unique", stmts[len(stmts)-1])
+	}
+}
+
+func TestWriteMigration(t *testing.T) {
+	dir := t.TempDir()
+	for _, e := range []string{"001_up_base.sql", "009_up_more.sql", "README"} {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, e), nil, 0644))
+	}
+
+	path, err := WriteMigration(dir, "ddl", nil)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, "", path)
+	}
+
+	path, err = WriteMigration(dir, "ddl", []string{"alter table a add column b text;", "alter table a add column c text;"})
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, filepath.Join(dir, "010_up_ddl.sql"), path)
+		data, err := os.ReadFile(path)
+		if assert.Nil(t, err, fmt.Sprint(err)) {
+			assert.Equal(t, "\nalter table a add column b text;\nalter table a add column c text;\n", string(data))
+		}
+	}
+}
+
+func TestMigration(t *testing.T) {
+	db := test.DB()
+	fm := entity.NewFieldMapper()
+	m := Map("ddl_entity", ddlEntity{})
+
+	stmts, err := Migration(db, fm, m)
+	if assert.Nil(t, err, fmt.Sprint(err)) && assert.Len(t, stmts, 1) {
+		_, err = db.Exec(stmts[0])
+		if assert.Nil(t, err, fmt.Sprint(err)) {
+			table, err := ReadTable(db, "ddl_entity")
+			if assert.Nil(t, err, fmt.Sprint(err)) {
+				stmts, err = Diff(fm, m, table)
+				assert.Nil(t, err, fmt.Sprint(err))
+				assert.Equal(t, []string(nil), stmts)
+			}
+			err = Validate(db, fm, m)
+			assert.Nil(t, err, fmt.Sprint(err))
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Nullability determines how generated structs represent nullable columns.
+type Nullability int
+
+const (
+	NullablePointer   Nullability = iota // nullable columns are pointers
+	NullableOmitEmpty                    // nullable columns are values tagged with omitempty
+)
+
+// StructConfig configures the generation of entity structs.
+type StructConfig struct {
+	Package  string
+	Nullable Nullability
+	Tabler   bool // generate a Table method for each struct; see entity.Tabler
+}
+
+// GenerateStructs produces Go source which declares an entity struct for
+// each of the provided tables. Each column is mapped by a db tag to a field
+// with a type derived from the column's type; primary keys are tagged with
+// the pk option and keys which are generated by the database with the
+// generated option. Columns of a type which has no obvious Go equivalent
+// are mapped to strings.
+func GenerateStructs(tables []*Table, conf StructConfig) ([]byte, error) {
+	imports := make(map[string]struct{})
+	types := make(map[string]struct{})
+	body := &bytes.Buffer{}
+
+	for _, t := range tables {
+		name := uniqueName(goName(t.Name), types)
+		fields := make(map[string]struct{})
+		if conf.Tabler {
+			fields["Table"] = struct{}{} // reserved for the method
+		}
+		keys := make(map[string]struct{})
+		for _, k := range t.PrimaryKey {
+			keys[k] = struct{}{}
+		}
+
+		fmt.Fprintf(body, "\n// %s is stored in the table %s.\n", name, t.Name)
+		fmt.Fprintf(body, "type %s struct {\n", name)
+		for _, c := range t.Columns {
+			typ, opts, pkg := goType(c.Type)
+			if pkg != "" {
+				imports[pkg] = struct{}{}
+			}
+			if _, ok := keys[c.Name]; ok {
+				opts = append([]string{"pk"}, opts...)
+			}
+			if c.Generated || strings.HasPrefix(c.Default, "nextval(") {
+				opts = append(opts, "generated")
+			}
+			if c.Nullable && !nilable(typ) {
+				if conf.Nullable == NullableOmitEmpty {
+					opts = append(opts, "omitempty")
+				} else {
+					typ = "*" + typ
+				}
+			}
+			tag := strings.Join(append([]string{c.Name}, opts...), ",")
+			fmt.Fprintf(body, "\t%s %s `db:%q`\n", uniqueName(goName(c.Name), fields), typ, tag)
+		}
+		fmt.Fprintf(body, "}\n")
+
+		if conf.Tabler {
+			fmt.Fprintf(body, "\nfunc (%s) Table() string { return %q }\n", name, t.Name)
+		}
+	}
+
+	src := &bytes.Buffer{}
+	fmt.Fprintf(src, "package %s\n", conf.Package)
+	if len(imports) > 0 {
+		pkgs := make([]string, 0, len(imports))
+		for k := range imports {
+			pkgs = append(pkgs, k)
+		}
+		sort.Strings(pkgs)
+		fmt.Fprintf(src, "\nimport (\n")
+		for _, e := range pkgs {
+			fmt.Fprintf(src, "\t%q\n", e)
+		}
+		fmt.Fprintf(src, ")\n")
+	}
+	src.Write(body.Bytes())
+
+	return format.Source(src.Bytes())
+}
+
+// goType produces the Go type of a column type, the options the field must
+// be tagged with and the package the type is declared in, if any.
+func goType(ctype string) (string, []string, string) {
+	if e, ok := strings.CutPrefix(ctype, "_"); ok { // a Postgres array
+		// element types are limited to those the driver can scan arrays of
+		switch t, _, _ := goType(e); t {
+		case "bool", "float64", "[]byte":
+			return "[]" + t, []string{"array"}, ""
+		case "int16", "int32", "int64":
+			return "[]int64", []string{"array"}, ""
+		case "float32":
+			return "[]float64", []string{"array"}, ""
+		default:
+			return "[]string", []string{"array"}, ""
+		}
+	}
+	if e, ok := strings.CutSuffix(ctype, "[]"); ok {
+		return goType("_" + e)
+	}
+	base, _, _ := strings.Cut(ctype, "(") // varchar(64), numeric(10, 2), etc.
+	switch strings.TrimSpace(base) {
+	case "bool", "boolean":
+		return "bool", nil, ""
+	case "int2", "smallint":
+		return "int16", nil, ""
+	case "int4", "int", "integer", "serial":
+		return "int32", nil, ""
+	case "int8", "bigint", "bigserial":
+		return "int64", nil, ""
+	case "float4", "real":
+		return "float32", nil, ""
+	case "float8", "double", "double precision", "float":
+		return "float64", nil, ""
+	case "bytea", "blob":
+		return "[]byte", nil, ""
+	case "json", "jsonb":
+		return "json.RawMessage", []string{"json"}, "encoding/json"
+	case "timestamp", "timestamptz", "timestamp with time zone", "timestamp without time zone", "date", "datetime":
+		return "time.Time", nil, "time"
+	default: // text, varchar, uuid, numeric, enums and so on
+		return "string", nil, ""
+	}
+}
+
+// nilable determines if a Go type can represent NULL without being made a
+// pointer.
+func nilable(t string) bool {
+	return strings.HasPrefix(t, "[]") || t == "json.RawMessage"
+}
+
+// Common initialisms, which are capitalized in Go names.
+var initialisms = map[string]struct{}{
+	"api": {}, "html": {}, "http": {}, "https": {}, "id": {}, "ip": {}, "json": {},
+	"sql": {}, "ssn": {}, "uid": {}, "uri": {}, "url": {}, "uuid": {}, "xml": {},
+}
+
+// goName converts a snake case identifier to an exported Go name. Runes
+// which are not ASCII letters or digits separate words and are otherwise
+// dropped.
+func goName(n string) string {
+	b := &strings.Builder{}
+	for _, e := range strings.FieldsFunc(n, func(r rune) bool { return !isIdentRune(r) }) {
+		l := strings.ToLower(e)
+		if _, ok := initialisms[l]; ok {
+			b.WriteString(strings.ToUpper(l))
+		} else {
+			b.WriteString(strings.ToUpper(l[:1]) + l[1:])
+		}
+	}
+	s := b.String()
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		s = "X" + s
+	}
+	return s
+}
+
+func isIdentRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// uniqueName produces a name which is not in use, by numbering it if it is,
+// and records it as being in use.
+func uniqueName(n string, used map[string]struct{}) string {
+	u := n
+	for i := 2; ; i++ {
+		if _, ok := used[u]; !ok {
+			break
+		}
+		u = n + strconv.Itoa(i)
+	}
+	used[u] = struct{}{}
+	return u
+}
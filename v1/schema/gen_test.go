@@ -0,0 +1,153 @@
+package schema
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bww/go-dbx/v1/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateStructs(t *testing.T) {
+	tables := []*Table{
+		{
+			Name: "user_accounts",
+			Columns: []Column{
+				{Name: "id", Type: "int8", Default: "nextval('user_accounts_id_seq'::regclass)"},
+				{Name: "user_name", Type: "varchar"},
+				{Name: "api_key", Type: "uuid", Nullable: true},
+				{Name: "attrs", Type: "jsonb", Nullable: true},
+				{Name: "tags", Type: "_text", Nullable: true},
+				{Name: "scores", Type: "_int4"},
+				{Name: "created_at", Type: "timestamptz"},
+				{Name: "deleted_at", Type: "timestamptz", Nullable: true},
+				{Name: "balance", Type: "numeric"},
+				{Name: "flag", Type: "bool", Nullable: true},
+			},
+			PrimaryKey: []string{"id"},
+		},
+		{
+			Name: "members",
+			Columns: []Column{
+				{Name: "account_id", Type: "int8"},
+				{Name: "user_id", Type: "text"},
+				{Name: "rank", Type: "integer", Nullable: true},
+				{Name: "data", Type: "blob", Nullable: true},
+			},
+			PrimaryKey: []string{"account_id", "user_id"},
+		},
+	}
+
+	src, err := GenerateStructs(tables, StructConfig{Package: "entities", Tabler: true})
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, "package entities\n\n"+
+			"import (\n\t\"encoding/json\"\n\t\"time\"\n)\n\n"+
+			"// UserAccounts is stored in the table user_accounts.\n"+
+			"type UserAccounts struct {\n"+
+			"\tID        int64           `db:\"id,pk,generated\"`\n"+
+			"\tUserName  string          `db:\"user_name\"`\n"+
+			"\tAPIKey    *string         `db:\"api_key\"`\n"+
+			"\tAttrs     json.RawMessage `db:\"attrs,json\"`\n"+
+			"\tTags      []string        `db:\"tags,array\"`\n"+
+			"\tScores    []int64         `db:\"scores,array\"`\n"+
+			"\tCreatedAt time.Time       `db:\"created_at\"`\n"+
+			"\tDeletedAt *time.Time      `db:\"deleted_at\"`\n"+
+			"\tBalance   string          `db:\"balance\"`\n"+
+			"\tFlag      *bool           `db:\"flag\"`\n"+
+			"}\n\n"+
+			"func (UserAccounts) Table() string { return \"user_accounts\" }\n\n"+
+			"// Members is stored in the table members.\n"+
+			"type Members struct {\n"+
+			"\tAccountID int64  `db:\"account_id,pk\"`\n"+
+			"\tUserID    string `db:\"user_id,pk\"`\n"+
+			"\tRank      *int32 `db:\"rank\"`\n"+
+			"\tData      []byte `db:\"data\"`\n"+
+			"}\n\n"+
+			"func (Members) Table() string { return \"members\" }\n", string(src))
+	}
+
+	src, err = GenerateStructs(tables[1:], StructConfig{Package: "x", Nullable: NullableOmitEmpty})
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Contains(t, string(src), "\tRank      int32  `db:\"rank,omitempty\"`\n")
+		assert.NotContains(t, string(src), "Table()")
+	}
+}
+
+func TestGenerateStructsFromDB(t *testing.T) {
+	db := test.DB()
+
+	names, err := ListTables(db)
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Contains(t, names, "first_entity")
+	}
+
+	table, err := ReadTable(db, "first_entity")
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		src, err := GenerateStructs([]*Table{table}, StructConfig{Package: "entities"})
+		if assert.Nil(t, err, fmt.Sprint(err)) {
+			assert.Equal(t, "package entities\n\n"+
+				"// FirstEntity is stored in the table first_entity.\n"+
+				"type FirstEntity struct {\n"+
+				"\tA string  `db:\"a,pk\"`\n"+
+				"\tB *string `db:\"b\"`\n"+
+				"\tC *int32  `db:\"c\"`\n"+
+				"\tE *int32  `db:\"e\"`\n"+
+				"}\n", string(src))
+		}
+	}
+}
+
+func TestGoName(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Expect string
+	}{
+		{"user_id", "UserID"},
+		{"userId", "Userid"},
+		{"a__b", "AB"},
+		{"first-name", "FirstName"},
+		{"price ($)", "Price"},
+		{"naïve", "NaVe"},
+		{"2fa_code", "X2faCode"},
+		{"$$", "X"},
+	}
+	for _, e := range tests {
+		assert.Equal(t, e.Expect, goName(e.Name), e.Name)
+	}
+}
+
+func TestGenerateStructsUniqueNames(t *testing.T) {
+	tables := []*Table{
+		{
+			Name: "a_b",
+			Columns: []Column{
+				{Name: "a_b", Type: "text"},
+				{Name: "a__b", Type: "text"},
+				{Name: "a-b", Type: "text"},
+				{Name: "table", Type: "text"},
+			},
+		},
+		{
+			Name:    "a__b",
+			Columns: []Column{{Name: "x", Type: "text"}},
+		},
+	}
+
+	src, err := GenerateStructs(tables, StructConfig{Package: "entities", Tabler: true})
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, "package entities\n\n"+
+			"// AB is stored in the table a_b.\n"+
+			"type AB struct {\n"+
+			"\tAB     string `db:\"a_b\"`\n"+
+			"\tAB2    string `db:\"a__b\"`\n"+
+			"\tAB3    string `db:\"a-b\"`\n"+
+			"\tTable2 string `db:\"table\"`\n"+
+			"}\n\n"+
+			"func (AB) Table() string { return \"a_b\" }\n\n"+
+			"// AB2 is stored in the table a__b.\n"+
+			"type AB2 struct {\n"+
+			"\tX string `db:\"x\"`\n"+
+			"}\n\n"+
+			"func (AB2) Table() string { return \"a__b\" }\n", string(src))
+	}
+}
@@ -0,0 +1,168 @@
+package schema
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bww/go-dbx/v1"
+	"github.com/bww/go-dbx/v1/entity"
+)
+
+// Diff produces the statements which bring a table in line with a mapping.
+// Only changes which are safe to apply to a table that already has rows are
+// produced as statements; others are produced as commented-out statements,
+// which should be reviewed and adapted before they are used. New columns are
+// added as nullable, with a comment which would make them NOT NULL, and the
+// type of an existing column is only changed when the field cannot be stored
+// in it. Columns which are not mapped are never dropped, and a comment is
+// produced when the primary keys of the table and mapping differ.
+func Diff(fm *entity.FieldMapper, m Mapping, table *Table) ([]string, error) {
+	defs, err := columnDefs(fm, m)
+	if err != nil {
+		return nil, err
+	}
+
+	var stmts []string
+	alter := func(f string, a ...interface{}) {
+		stmts = append(stmts, fmt.Sprintf("alter table %s "+f+";", append([]interface{}{m.Table}, a...)...))
+	}
+	suggest := func(why, f string, a ...interface{}) {
+		stmts = append(stmts, fmt.Sprintf("-- alter table %s "+f+"; -- "+why, append([]interface{}{m.Table}, a...)...))
+	}
+
+	tmap := fm.TypeMap(m.Type)
+	mapped := make(map[string]struct{})
+	for _, d := range defs {
+		mapped[d.Name] = struct{}{}
+		c, ok := table.Column(d.Name)
+		if !ok {
+			if d.Override || d.Nullable || d.Identity {
+				alter("add column %s %s", d.Name, d)
+			} else {
+				n := d
+				n.Nullable = true
+				alter("add column %s %s", d.Name, n)
+				suggest("once every row has a value", "alter column %s set not null", d.Name)
+			}
+			continue
+		}
+		if d.Override {
+			continue // we don't interpret definitions provided by tags
+		}
+		if f := tmap.Names[d.Name]; !fieldCompatible(fm, m.Type, f.Index, f.Field.Type, c.Type) {
+			suggest(fmt.Sprintf("%v cannot be stored as %s", f.Field.Type, c.Type), "alter column %s type %s", d.Name, d.Type)
+		}
+		if !d.Nullable && c.Nullable {
+			suggest("once every row has a value", "alter column %s set not null", d.Name)
+		} else if d.Nullable && !c.Nullable {
+			alter("alter column %s drop not null", d.Name)
+		}
+	}
+
+	for _, c := range table.Columns {
+		if _, ok := mapped[c.Name]; !ok {
+			suggest("not mapped", "drop column %s", c.Name)
+		}
+	}
+
+	keys := fm.KeysForType(m.Type)
+	sortByColumn(keys, defs)
+	if len(keys) > 0 && len(table.PrimaryKey) == 0 {
+		suggest("once the key columns are unique", "add primary key (%s)", strings.Join(keys, ", "))
+	} else if !sameSet(keys, table.PrimaryKey) {
+		stmts = append(stmts, fmt.Sprintf("-- primary key of %s is (%s) but is mapped as (%s)", m.Table, strings.Join(table.PrimaryKey, ", "), strings.Join(keys, ", ")))
+	}
+
+	return stmts, nil
+}
+
+// Migration produces the statements which bring the tables of the provided
+// mappings in line with them: tables which do not exist are created and
+// those which do are altered as described by Diff.
+func Migration(cxt dbx.Context, fm *entity.FieldMapper, mappings ...Mapping) ([]string, error) {
+	var stmts []string
+	for _, m := range mappings {
+		if m.Table == "" {
+			return nil, dbx.ErrNoTable
+		}
+		table, err := ReadTable(cxt, m.Table)
+		if err == dbx.ErrNotFound {
+			ddl, err := CreateTable(fm, m)
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, ddl)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		alter, err := Diff(fm, m, table)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, alter...)
+	}
+	return stmts, nil
+}
+
+var migrationName = regexp.MustCompile(`^(\d+)_`)
+
+// WriteMigration writes statements to a new migration in the provided
+// directory. Migrations are named like 001_up_base.sql; the new migration
+// is numbered after the last one in the directory. The path of the file
+// that was written is returned. If there are no statements, nothing is
+// written and the empty string is returned.
+func WriteMigration(dir, name string, stmts []string) (string, error) {
+	if len(stmts) == 0 {
+		return "", nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+
+	n, w := 0, 3
+	for _, e := range names {
+		if m := migrationName.FindStringSubmatch(e); m != nil {
+			v, err := strconv.Atoi(m[1])
+			if err == nil && v > n {
+				n, w = v, len(m[1])
+			}
+		}
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%0*d_up_%s.sql", w, n+1, name))
+	data := "\n" + strings.Join(stmts, "\n") + "\n"
+	err = os.WriteFile(path, []byte(data), 0644)
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	x := append([]string(nil), a...)
+	y := append([]string(nil), b...)
+	sort.Strings(x)
+	sort.Strings(y)
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
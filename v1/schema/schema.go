@@ -0,0 +1,216 @@
+// The schema package inspects the tables in a database and compares them
+// to the way entities are mapped to columns.
+package schema
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/bww/go-dbx/v1"
+	"github.com/bww/go-dbx/v1/errors"
+)
+
+// Column describes a column of a table as it exists in the database.
+type Column struct {
+	Name      string
+	Type      string // the type name, as in pg_type; arrays are prefixed by an underscore, e.g., _text
+	Nullable  bool
+	Default   string // the default expression, if any
+	Generated bool   // the column is an identity column or is generated
+}
+
+// Table describes a table as it exists in the database.
+type Table struct {
+	Schema     string
+	Name       string
+	Columns    []Column
+	PrimaryKey []string
+}
+
+// Column produces the named column of a table.
+func (t *Table) Column(name string) (Column, bool) {
+	for _, e := range t.Columns {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return Column{}, false
+}
+
+const columnsQuery = `
+SELECT
+  c.table_schema, c.column_name, c.udt_name, c.is_nullable = 'YES',
+  COALESCE(c.column_default, ''), c.is_identity = 'YES' OR c.is_generated = 'ALWAYS'
+FROM information_schema.columns c
+WHERE c.table_schema = COALESCE(NULLIF($1, ''), current_schema()) AND c.table_name = $2
+ORDER BY c.ordinal_position`
+
+const primaryKeyQuery = `
+SELECT a.attname
+FROM pg_index i
+JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+WHERE i.indrelid = (quote_ident($1) || '.' || quote_ident($2))::regclass AND i.indisprimary
+ORDER BY array_position(i.indkey::int2[], a.attnum)`
+
+const tablesQuery = `
+SELECT table_name
+FROM information_schema.tables
+WHERE table_schema = current_schema() AND table_type = 'BASE TABLE'
+ORDER BY table_name`
+
+const sqliteTablesQuery = `
+SELECT name
+FROM sqlite_master
+WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+ORDER BY name`
+
+// isSQLite determines if a context is backed by SQLite; otherwise Postgres
+// is assumed.
+func isSQLite(cxt dbx.Context) bool {
+	n, ok := cxt.(dbx.DriverNamer)
+	return ok && n.DriverName() == "sqlite3"
+}
+
+// ListTables produces the names of the tables in the current schema.
+func ListTables(cxt dbx.Context) ([]string, error) {
+	query := tablesQuery
+	if isSQLite(cxt) {
+		query = sqliteTablesQuery
+	}
+	rows, err := cxt.Query(query)
+	if err != nil {
+		return nil, errors.NewWithSQL(err, query)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var n string
+		err = rows.Scan(&n)
+		if err != nil {
+			return nil, errors.NewWithSQL(err, query)
+		}
+		names = append(names, n)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.NewWithSQL(err, query)
+	}
+	return names, nil
+}
+
+// ReadTable reads the description of a table from the database. The name
+// may be qualified by a schema; otherwise the current schema is used. If the
+// table does not exist, ErrNotFound is returned.
+//
+// SQLite databases are supported as well, in which case column types are
+// reported as they are declared, in lower case.
+func ReadTable(cxt dbx.Context, name string) (*Table, error) {
+	if isSQLite(cxt) {
+		return readSQLiteTable(cxt, name)
+	}
+
+	var schema string
+	table := name
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		schema, table = name[:i], name[i+1:]
+	}
+
+	rows, err := cxt.Query(columnsQuery, schema, table)
+	if err != nil {
+		return nil, errors.NewWithSQL(err, columnsQuery)
+	}
+	defer rows.Close()
+
+	t := &Table{Name: table}
+	for rows.Next() {
+		var c Column
+		err = rows.Scan(&t.Schema, &c.Name, &c.Type, &c.Nullable, &c.Default, &c.Generated)
+		if err != nil {
+			return nil, errors.NewWithSQL(err, columnsQuery)
+		}
+		t.Columns = append(t.Columns, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.NewWithSQL(err, columnsQuery)
+	}
+	if len(t.Columns) == 0 {
+		return nil, dbx.ErrNotFound
+	}
+
+	keys, err := cxt.Query(primaryKeyQuery, t.Schema, t.Name)
+	if err != nil {
+		return nil, errors.NewWithSQL(err, primaryKeyQuery)
+	}
+	defer keys.Close()
+	for keys.Next() {
+		var k string
+		err = keys.Scan(&k)
+		if err != nil {
+			return nil, errors.NewWithSQL(err, primaryKeyQuery)
+		}
+		t.PrimaryKey = append(t.PrimaryKey, k)
+	}
+	if err = keys.Err(); err != nil {
+		return nil, errors.NewWithSQL(err, primaryKeyQuery)
+	}
+
+	return t, nil
+}
+
+// readSQLiteTable reads the description of a table from an SQLite database.
+func readSQLiteTable(cxt dbx.Context, name string) (*Table, error) {
+	query := `SELECT name, type, "notnull", COALESCE(dflt_value, ''), pk FROM pragma_table_info(?) ORDER BY cid`
+	rows, err := cxt.Query(query, name)
+	if err != nil {
+		return nil, errors.NewWithSQL(err, query)
+	}
+	defer rows.Close()
+
+	t := &Table{Schema: "main", Name: name}
+	var keys []int
+	for rows.Next() {
+		var c Column
+		var notnull bool
+		var pk int
+		err = rows.Scan(&c.Name, &c.Type, &notnull, &c.Default, &pk)
+		if err != nil {
+			return nil, errors.NewWithSQL(err, query)
+		}
+		c.Type = strings.ToLower(c.Type)
+		c.Nullable = !notnull && pk == 0
+		if pk > 0 {
+			keys = append(keys, pk)
+			t.PrimaryKey = append(t.PrimaryKey, c.Name)
+			// a single integer primary key is an alias for the rowid, which is generated
+			c.Generated = c.Type == "integer"
+		}
+		t.Columns = append(t.Columns, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.NewWithSQL(err, query)
+	}
+	if len(t.Columns) == 0 {
+		return nil, dbx.ErrNotFound
+	}
+
+	if len(t.PrimaryKey) > 1 { // only a single key is a rowid alias; order the columns as declared in the key
+		for i := range t.Columns {
+			t.Columns[i].Generated = false
+		}
+		sort.Sort(byPosition{keys, t.PrimaryKey})
+	}
+
+	return t, nil
+}
+
+type byPosition struct {
+	pos   []int
+	names []string
+}
+
+func (b byPosition) Len() int           { return len(b.pos) }
+func (b byPosition) Less(i, j int) bool { return b.pos[i] < b.pos[j] }
+func (b byPosition) Swap(i, j int) {
+	b.pos[i], b.pos[j] = b.pos[j], b.pos[i]
+	b.names[i], b.names[j] = b.names[j], b.names[i]
+}
@@ -0,0 +1,122 @@
+package schema
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bww/go-dbx/v1/entity"
+	"github.com/bww/go-dbx/v1/test"
+	"github.com/bww/go-util/v1/env"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	test.Init(testDB, test.WithMigrations(env.Etc("migrations")))
+	os.Exit(m.Run())
+}
+
+const testDB = "dbx_v1_schema_test"
+
+type firstEntity struct {
+	A string `db:"a,pk"`
+	B string `db:"b"`
+	C int    `db:"c"`
+	E int    `db:"e,omitempty"`
+}
+
+type mismatchedEntity struct {
+	A string    `db:"a,pk"`
+	B time.Time `db:"b"`
+	C string    `db:"c"`
+	X int       `db:"x"`
+}
+
+type keylessEntity struct {
+	A string `db:"a"`
+}
+
+func (keylessEntity) Table() string { return "first_entity" }
+
+func TestReadTable(t *testing.T) {
+	db := test.DB()
+
+	tab, err := ReadTable(db, "first_entity")
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		assert.Equal(t, "public", tab.Schema)
+		assert.Equal(t, []Column{
+			{Name: "a", Type: "varchar"},
+			{Name: "b", Type: "varchar", Nullable: true},
+			{Name: "c", Type: "int4", Nullable: true},
+			{Name: "e", Type: "int4", Nullable: true},
+		}, tab.Columns)
+		assert.Equal(t, []string{"a"}, tab.PrimaryKey)
+	}
+
+	tab, err = ReadTable(db, "public.serial_entity")
+	if assert.Nil(t, err, fmt.Sprint(err)) {
+		c, ok := tab.Column("id")
+		if assert.True(t, ok) {
+			assert.Equal(t, "int8", c.Type)
+			assert.Contains(t, c.Default, "nextval")
+		}
+	}
+
+	_, err = ReadTable(db, "no_such_table")
+	assert.NotNil(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	db := test.DB()
+	fm := entity.NewFieldMapper()
+
+	err := Validate(db, fm, Map("first_entity", &firstEntity{}))
+	assert.Nil(t, err, fmt.Sprint(err))
+
+	err = Validate(db, fm, Map("first_entity", mismatchedEntity{}), Map("", keylessEntity{}), Map("no_such_table", firstEntity{}))
+	if assert.IsType(t, &Error{}, err) {
+		assert.Equal(t, []Problem{
+			{Kind: TypeMismatch, Table: "first_entity", Column: "b", Message: "field B of type time.Time cannot be stored as varchar"},
+			{Kind: TypeMismatch, Table: "first_entity", Column: "c", Message: "field C of type string cannot be stored as int4"},
+			{Kind: MissingColumn, Table: "first_entity", Column: "x", Message: "column is mapped to X but does not exist"},
+			{Kind: MissingPrimaryKey, Table: "first_entity", Message: "entity does not declare a primary key"},
+			{Kind: MissingTable, Table: "no_such_table", Message: "table does not exist"},
+		}, err.(*Error).Problems)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	fm := entity.NewFieldMapper()
+	table := &Table{
+		Name: "t",
+		Columns: []Column{
+			{Name: "a", Type: "int8"},
+			{Name: "b", Type: "jsonb"},
+			{Name: "c", Type: "_text", Nullable: true},
+			{Name: "d", Type: "bytea", Nullable: true},
+			{Name: "e", Type: "timestamptz"},
+			{Name: "f", Type: "mood"}, // an enum
+			{Name: "g", Type: "text"},
+			{Name: "h", Type: "text", Default: "'x'::text"},
+			{Name: "i", Type: "int8", Generated: true},
+		},
+		PrimaryKey: []string{"a", "i"},
+	}
+
+	type compared struct {
+		A int64             `db:"a,pk"`
+		B map[string]string `db:"b,json"`
+		C []string          `db:"c,array"`
+		D string            `db:"d,encrypt"`
+		E *time.Time        `db:"e"`
+		F string            `db:"f"`
+	}
+
+	problems := compare(fm, Mapping{Table: "t", Type: reflect.TypeOf(compared{})}, table)
+	assert.Equal(t, []Problem{
+		{Kind: UnmappedColumn, Table: "t", Column: "g", Message: "column is NOT NULL without a default but is not mapped"},
+		{Kind: PrimaryKeyMismatch, Table: "t", Message: "entity declares (a) but table declares (a, i)"},
+	}, problems)
+}
@@ -0,0 +1,263 @@
+package schema
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bww/go-dbx/v1"
+	"github.com/bww/go-dbx/v1/entity"
+)
+
+// Mapping associates an entity type with the table it is stored in.
+type Mapping struct {
+	Table string
+	Type  reflect.Type
+}
+
+// Map creates a mapping for the type of the provided entity, which may be a
+// value or a pointer. If the table is empty, the table declared by the
+// entity is used; see entity.Tabler.
+func Map(table string, ent interface{}) Mapping {
+	typ := reflect.TypeOf(ent)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if table == "" {
+		table, _ = entity.TableForType(typ)
+	}
+	return Mapping{Table: table, Type: typ}
+}
+
+// ProblemKind identifies the kind of a problem with a mapping.
+type ProblemKind int
+
+const (
+	MissingTable       ProblemKind = iota // the table does not exist
+	MissingColumn                         // a mapped column does not exist
+	UnmappedColumn                        // a column requires a value but is not mapped
+	TypeMismatch                          // a field cannot be stored in the column it is mapped to
+	MissingPrimaryKey                     // the entity or the table does not declare a primary key
+	PrimaryKeyMismatch                    // the entity and table declare different primary keys
+)
+
+var problemNames = map[ProblemKind]string{
+	MissingTable:       "missing table",
+	MissingColumn:      "missing column",
+	UnmappedColumn:     "unmapped column",
+	TypeMismatch:       "type mismatch",
+	MissingPrimaryKey:  "missing primary key",
+	PrimaryKeyMismatch: "primary key mismatch",
+}
+
+func (k ProblemKind) String() string {
+	return problemNames[k]
+}
+
+// Problem describes a difference between the way an entity is mapped and
+// the table it is stored in.
+type Problem struct {
+	Kind    ProblemKind
+	Table   string
+	Column  string // the column the problem concerns, if any
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Column == "" {
+		return fmt.Sprintf("%s: %s: %s", p.Table, p.Kind, p.Message)
+	}
+	return fmt.Sprintf("%s.%s: %s: %s", p.Table, p.Column, p.Kind, p.Message)
+}
+
+// Error is produced when mappings do not agree with the database. It
+// describes every problem that was found.
+type Error struct {
+	Problems []Problem
+}
+
+func (e *Error) Error() string {
+	b := &strings.Builder{}
+	b.WriteString("Schema does not match entities: ")
+	for i, p := range e.Problems {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(p.String())
+	}
+	return b.String()
+}
+
+// Validate compares the provided mappings with the tables in the database.
+// It reports mapped columns which do not exist, columns which require a
+// value but are not mapped, fields which cannot be stored in the columns
+// they are mapped to and primary keys which are missing or disagree. If
+// any problems are found, an *Error describing them is returned. For
+// example, on startup:
+//
+//	err := schema.Validate(db, fm, schema.Map("users", User{}), schema.Map("", Account{}))
+func Validate(cxt dbx.Context, fm *entity.FieldMapper, mappings ...Mapping) error {
+	var problems []Problem
+	for _, m := range mappings {
+		p, err := validate(cxt, fm, m)
+		if err != nil {
+			return err
+		}
+		problems = append(problems, p...)
+	}
+	if len(problems) > 0 {
+		return &Error{Problems: problems}
+	}
+	return nil
+}
+
+func validate(cxt dbx.Context, fm *entity.FieldMapper, m Mapping) ([]Problem, error) {
+	if m.Table == "" {
+		return nil, dbx.ErrNoTable
+	}
+	table, err := ReadTable(cxt, m.Table)
+	if err == dbx.ErrNotFound {
+		return []Problem{{Kind: MissingTable, Table: m.Table, Message: "table does not exist"}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return compare(fm, m, table), nil
+}
+
+// compare produces the problems with a mapping given the table it is
+// stored in.
+func compare(fm *entity.FieldMapper, m Mapping, table *Table) []Problem {
+	var problems []Problem
+	add := func(k ProblemKind, col, f string, a ...interface{}) {
+		problems = append(problems, Problem{Kind: k, Table: m.Table, Column: col, Message: fmt.Sprintf(f, a...)})
+	}
+
+	cols := fm.ColumnsForType(m.Type)
+	sort.Strings(cols)
+	mapped := make(map[string]struct{})
+	tmap := fm.TypeMap(m.Type)
+	for _, n := range cols {
+		mapped[n] = struct{}{}
+		c, ok := table.Column(n)
+		if !ok {
+			add(MissingColumn, n, "column is mapped to %s but does not exist", tmap.Names[n].Field.Name)
+			continue
+		}
+		f := tmap.Names[n]
+		if !fieldCompatible(fm, m.Type, f.Index, f.Field.Type, c.Type) {
+			add(TypeMismatch, n, "field %s of type %v cannot be stored as %s", f.Field.Name, f.Field.Type, c.Type)
+		}
+	}
+
+	for _, c := range table.Columns {
+		if _, ok := mapped[c.Name]; ok {
+			continue
+		}
+		if !c.Nullable && c.Default == "" && !c.Generated {
+			add(UnmappedColumn, c.Name, "column is NOT NULL without a default but is not mapped")
+		}
+	}
+
+	keys := fm.KeysForType(m.Type)
+	sort.Strings(keys)
+	pkey := append([]string(nil), table.PrimaryKey...)
+	sort.Strings(pkey)
+	if len(keys) == 0 {
+		add(MissingPrimaryKey, "", "entity does not declare a primary key")
+	} else if len(pkey) == 0 {
+		add(MissingPrimaryKey, "", "table does not have a primary key")
+	} else if !reflect.DeepEqual(keys, pkey) {
+		add(PrimaryKeyMismatch, "", "entity declares (%s) but table declares (%s)", strings.Join(keys, ", "), strings.Join(pkey, ", "))
+	}
+
+	return problems
+}
+
+var (
+	typeOfScanner = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	typeOfValuer  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	typeOfTime    = reflect.TypeOf(time.Time{})
+)
+
+// Families of builtin column types. Columns of a type which is not listed,
+// such as an enum or a type provided by an extension, are not checked.
+var (
+	integerTypes = []string{"int2", "int4", "int8", "oid"}
+	floatTypes   = []string{"float4", "float8", "numeric"}
+	textTypes    = []string{"text", "varchar", "bpchar", "char", "name", "citext", "uuid", "inet", "cidr", "macaddr", "xml", "interval", "numeric"}
+	jsonTypes    = []string{"json", "jsonb"}
+	timeTypes    = []string{"timestamp", "timestamptz", "date", "time", "timetz"}
+	builtinTypes = union(integerTypes, floatTypes, textTypes, jsonTypes, timeTypes, []string{"bool", "bytea"})
+)
+
+func fieldCompatible(fm *entity.FieldMapper, typ reflect.Type, index []int, ftype reflect.Type, ctype string) bool {
+	switch {
+	case fm.IsEncrypted(typ, index):
+		return ctype == "bytea"
+	case fm.IsJSON(typ, index):
+		return slices.Contains(jsonTypes, ctype) || ctype == "text"
+	case fm.IsArray(typ, index):
+		if !strings.HasPrefix(ctype, "_") {
+			return !slices.Contains(builtinTypes, ctype)
+		}
+		t := ftype
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		return compatible(fm, t.Elem(), ctype[1:])
+	default:
+		return compatible(fm, ftype, ctype)
+	}
+}
+
+// compatible determines if a value of a Go type can be stored in a column
+// of a database type.
+func compatible(fm *entity.FieldMapper, t reflect.Type, ctype string) bool {
+	if fm.Converts(t) {
+		return true
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Implements(typeOfValuer) || reflect.PointerTo(t).Implements(typeOfScanner) {
+		return true // we can't know what it accepts
+	}
+	if strings.HasPrefix(ctype, "_") {
+		return false // arrays must be tagged as such
+	}
+	if !slices.Contains(builtinTypes, ctype) {
+		return true
+	}
+	if t == typeOfTime {
+		return slices.Contains(timeTypes, ctype)
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return ctype == "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return slices.Contains(integerTypes, ctype) || ctype == "numeric"
+	case reflect.Float32, reflect.Float64:
+		return slices.Contains(floatTypes, ctype)
+	case reflect.String:
+		return slices.Contains(textTypes, ctype) || slices.Contains(jsonTypes, ctype)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return ctype == "bytea" || slices.Contains(jsonTypes, ctype)
+		}
+	}
+	return false
+}
+
+func union(sets ...[]string) []string {
+	var u []string
+	for _, e := range sets {
+		u = append(u, e...)
+	}
+	return u
+}